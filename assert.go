@@ -10,6 +10,9 @@ import (
 //BPTREE_DEBUG is set to t, true, yes, or on, then ASSERT=true,
 //else ASSERT=false. Capitalization in the BPTREE_DEBUG env var does not
 //matter as its contents are always lower cased.
+//
+//ASSERT also turns on the check that published versions are never
+//modified; see freeze.go.
 var ASSERT = strings.ToLower(os.Getenv("BPTREE_DEBUG")) == "t" ||
 	strings.ToLower(os.Getenv("BPTREE_DEBUG")) == "true" ||
 	strings.ToLower(os.Getenv("BPTREE_DEBUG")) == "yes" ||
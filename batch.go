@@ -0,0 +1,97 @@
+package bptree
+
+//BatchOp is one Put() or Del() of a batch passed to BpTree.Apply(). If Del
+//is true Key is deleted and Val is ignored, otherwise Val is put for Key.
+type BatchOp struct {
+	Del bool
+	Key BptKey
+	Val interface{}
+}
+
+//BatchOutcome says what a BatchOp did to the tree.
+type BatchOutcome int
+
+const (
+	//BatchNotFound is the outcome of deleting a key that was not present.
+	BatchNotFound BatchOutcome = iota
+	//BatchAdded is the outcome of putting a key that was not present.
+	BatchAdded
+	//BatchReplaced is the outcome of putting a key that was present.
+	BatchReplaced
+	//BatchRemoved is the outcome of deleting a key that was present.
+	BatchRemoved
+)
+
+func (o BatchOutcome) String() string {
+	switch o {
+	case BatchNotFound:
+		return "NotFound"
+	case BatchAdded:
+		return "Added"
+	case BatchReplaced:
+		return "Replaced"
+	case BatchRemoved:
+		return "Removed"
+	}
+	return "BatchOutcome(?)"
+}
+
+//BatchResult is the result of one BatchOp. OldVal is the value that was
+//replaced or removed; it is nil for BatchAdded and BatchNotFound.
+type BatchResult struct {
+	Outcome BatchOutcome
+	OldVal  interface{}
+}
+
+//Apply(ops) applies the puts and deletes of ops, in order, as one logical
+//change and returns the single new version of the tree, along with one
+//BatchResult per op. Later ops see the effects of earlier ones, so a key that
+//is put twice ends up with the second value.
+//
+//The ops are applied through a transient edit session, so any leaf or
+//interior node that more than one op lands on is copied once for the whole
+//batch instead of once per op. If no op changes the tree the receiver
+//itself is returned.
+//
+func (ot *tree) Apply(ops []BatchOp) (BpTree, []BatchResult) {
+	results := make([]BatchResult, len(ops))
+
+	t := ot.copy()
+	t.edit = &editT{live: true}
+
+	var changed bool
+	for i, op := range ops {
+		if op.Del {
+			old, removed := t.del(op.Key)
+			if removed {
+				results[i] = BatchResult{BatchRemoved, old}
+				changed = true
+			} else {
+				results[i] = BatchResult{BatchNotFound, nil}
+			}
+			continue
+		}
+
+		oldLeaf, path := t.findLeaf(op.Key)
+		if t.isNoopPut(oldLeaf, op.Key, op.Val) {
+			old, _ := oldLeaf.get(op.Key, t.cmp)
+			results[i] = BatchResult{BatchReplaced, old}
+			continue
+		}
+		old, added := t.putLeaf(oldLeaf, path, op.Key, op.Val)
+		if added {
+			results[i] = BatchResult{BatchAdded, nil}
+		} else {
+			results[i] = BatchResult{BatchReplaced, old}
+		}
+		changed = true
+	}
+
+	t.edit.live = false
+	t.edit = nil
+
+	if !changed {
+		return ot, results
+	}
+	return t.freeze(), results
+}
@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"reflect"
 )
 
 //BpTree implemntents all the User facing API for the B+Tree persistent
@@ -15,66 +16,134 @@ import (
 type BpTree interface {
 	IsEmpty() bool
 	Equals(BpTree) bool
+	EqualsFunc(BpTree, func(a, b interface{}) bool) bool
 	Order() int
 	String() string
 	NumberOfEntries() int
 	Depth() int
 	Get(BptKey) (interface{}, bool)
+	Iter() BptIter
+	Range(lo BptKey, loInc bool, hi BptKey, hiInc bool) BptIter
+	RevIter() BptIter
+	RevRange(lo BptKey, loInc bool, hi BptKey, hiInc bool) BptIter
+	PrefixScan(prefix BptKey, limit int) (BptIter, error)
+	Min() (BptKey, interface{}, bool)
+	Max() (BptKey, interface{}, bool)
+	Floor(BptKey) (BptKey, interface{}, bool)
+	Ceiling(BptKey) (BptKey, interface{}, bool)
+	Lower(BptKey) (BptKey, interface{}, bool)
+	Higher(BptKey) (BptKey, interface{}, bool)
+	Rank(BptKey) int
+	Select(int) (BptKey, interface{})
 	//Modifying Ops
 	Put(BptKey, interface{}) (BpTree, bool)
 	Del(BptKey) (BpTree, interface{}, bool)
+	DelRange(lo, hi BptKey) (BpTree, int)
+	PutIfAbsent(BptKey, interface{}) (BpTree, bool)
+	Replace(BptKey, interface{}) (BpTree, interface{}, bool)
+	CompareAndSwap(key BptKey, old, new interface{}) (BpTree, bool)
+	Update(BptKey, func(old interface{}, found bool) (new interface{}, keep bool)) BpTree
+	Apply([]BatchOp) (BpTree, []BatchResult)
+	SplitAt(BptKey) (BpTree, BpTree)
+	Transient() BpTransient
 }
 
 //BptKey is the interface the user must implement to create their own BptKey
-//type. The only provided one is StringKey with my own interpretation of what
-//less-than should mean for strings.
+//type. The provided ones are StringKey, with my own interpretation of what
+//less-than should mean for strings, LexStringKey, IntKey, Uint64Key,
+//BytesKey, TimeKey and the composite TupleKey. Keys of different provided
+//types can share a tree; they are ordered by type first.
 type BptKey interface {
 	Equals(BptKey) bool
 	LessThan(BptKey) bool
 	String() string
 }
 
-type nodeI interface {
+type nodeI[K, V any] interface {
 	String() string
-	equals(nodeI) bool
+	equals(nodeI[K, V]) bool
 	isToBig() bool
 	isLeaf() bool
-	findLeftMostKey() BptKey
+	findLeftMostKey() K
 	order() int
 	size() int
+	count() int
 	halfFullSize() int
 }
 
 var lgr = log.New(os.Stderr, "[bptree] ", log.Lshortfile)
 
-type tree struct {
-	root    nodeI
+//Tree is a type-parameterized persistent B+Tree. It has the same Put(),
+//Get(), Del() and Equals() semantics as the BpTree returned by NewBpTree(),
+//and the same structural sharing between versions, but keys and values are
+//stored unboxed, so there are no BptKey wrappers to write and no type
+//assertions on the values that come back out.
+//
+//Create one with NewTree() for any key type that supports the < operator,
+//or NewTreeFunc() with a comparison function for every other key type.
+//
+//As with BpTree, a *Tree is immutable. Put() and Del() return a new *Tree
+//that shares every node they did not have to modify with the receiver.
+//
+type Tree[K any, V any] struct {
+	root    nodeI[K, V]
 	order   int
 	numEnts int
 	depth   int
+	//edit is non-nil only for the tree of a transient; see transient.go
+	edit *editT
+	//valEq is set by WithValEquals(); see options.go
+	valEq func(a, b V) bool
+	//cmp orders the keys; see WithCompare() in options.go
+	cmp compareFunc[K]
 }
 
-func mkTree(order int) *tree {
-	var t = new(tree)
-	t.root = mkLeaf(order)
+//tree is the BpTree: a Tree of BptKey keys and interface{} values, with the
+//methods whose BpTree signatures differ from the Tree ones.
+type tree struct {
+	Tree[BptKey, interface{}]
+}
+
+//mkTree returns an empty Tree by value, so that a tree can embed it without
+//a second allocation.
+func mkTree[K, V any](order int, cmp compareFunc[K]) Tree[K, V] {
+	var t Tree[K, V]
+	t.root = mkLeaf[K, V](order)
 	t.order = order
 	t.numEnts = 0
 	t.depth = 0
+	t.cmp = cmp
 	return t
 }
 
+//creates a shallow copy of the old *Tree structure returning a new *Tree
+//structure
+func (ot *Tree[K, V]) copy() *Tree[K, V] {
+	t := ot.dup()
+	return &t
+}
+
 //creates a shallow copy of the old *tree structure returning a new *tree
 //structure
 func (ot *tree) copy() *tree {
-	t := mkTree(ot.order)
-	t.root = ot.root
-	t.order = ot.order
-	t.numEnts = ot.numEnts
-	t.depth = ot.depth
+	return &tree{ot.dup()}
+}
+
+//dup returns a copy of ot's fields that is not part of ot's transient, if
+//ot has one.
+func (ot *Tree[K, V]) dup() Tree[K, V] {
+	t := *ot
+	t.edit = nil
 	return t
 }
 
-func (t *tree) setRootNode(node *interiorNodeS) {
+//freeze is Tree.freeze() for the BpTree.
+func (t *tree) freeze() *tree {
+	t.Tree.freeze()
+	return t
+}
+
+func (t *Tree[K, V]) setRootNode(node *interiorNodeS[K, V]) {
 	t.root = node
 	if len(node.keys) == 0 {
 		t.depth--
@@ -82,14 +151,16 @@ func (t *tree) setRootNode(node *interiorNodeS) {
 	}
 }
 
-func (t *tree) setRootLeaf(leaf *leafNodeS) {
+func (t *Tree[K, V]) setRootLeaf(leaf *leafNodeS[K, V]) {
 	t.root = leaf
 }
 
-func (t *tree) newRootNode(k BptKey, l, r nodeI) {
-	node := mkNode(t.order)
+func (t *Tree[K, V]) newRootNode(k K, l, r nodeI[K, V]) {
+	node := mkNode[K, V](t.order)
 	node.keys = append(node.keys, k)
 	node.vals = append(node.vals, l, r)
+	node.cnts = append(node.cnts, l.count(), r.count())
+	node.edit = t.edit
 
 	t.root = node
 	t.depth++
@@ -106,21 +177,26 @@ func (t *tree) newRootNode(k BptKey, l, r nodeI) {
 //between 16 and 31 entries 7 times to find any entry in the index. And
 //practically that is more towards 16 than 31 and 6 times is more common than 7.
 //
-//The B+Tree order is a constant for the life of a B+Tree.
-func NewBpTree(order int) BpTree {
+//The B+Tree order is a constant for the life of a B+Tree. So are the opts,
+//which every version derived from the B+Tree inherits.
+func NewBpTree(order int, opts ...Option) BpTree {
 	if order < 3 {
 		lgr.Panic("Cannot make a BpTree with lessthan order=3")
 	}
-	return mkTree(order)
+	t := &tree{mkTree[BptKey, interface{}](order, defaultCompare)}
+	for _, opt := range opts {
+		opt(t)
+	}
+	return t.freeze()
 }
 
-func (t *tree) IsEmpty() bool {
+func (t *Tree[K, V]) IsEmpty() bool {
 	var emptyByNumEnts bool
 	if t.numEnts == 0 {
 		emptyByNumEnts = true
 	}
 	var emptyByRootLeaf bool
-	rootLeaf, ok := t.root.(*leafNodeS)
+	rootLeaf, ok := t.root.(*leafNodeS[K, V])
 	if ok {
 		assert(len(rootLeaf.keys) == len(rootLeaf.vals), "tree.IsEmpty: len(rootLeaf.keys) != len(rootLeaf.vals)")
 		if len(rootLeaf.keys) == 0 {
@@ -131,171 +207,96 @@ func (t *tree) IsEmpty() bool {
 	return emptyByNumEnts && emptyByRootLeaf
 }
 
-//Equals() does a Deep equivelence check between trees.
+//Equals() does a Deep equivelence check between trees. Two trees are equal
+//if they hold the same keys mapped to the same values, no matter what order
+//the entries were Put() in or what shape the trees ended up with. Values are
+//compared with ==, except for values that are not comparable (which would
+//make == panic), which are compared with reflect.DeepEqual(). Use
+//EqualsFunc() to supply a different value comparison.
 //
 func (t *tree) Equals(other BpTree) bool {
-	ot := other.(*tree)
+	return t.EqualsFunc(other, nil)
+}
+
+//EqualsFunc() is Equals() with values compared by eq. A nil eq compares
+//values the same way Equals() does.
+//
+//Versions of a persistent tree usually share most of their nodes, so when
+//both trees reach the start of the same node at the same point of the walk
+//the whole subtree is skipped without comparing its entries.
+//
+func (t *tree) EqualsFunc(other BpTree, eq func(a, b interface{}) bool) bool {
+	return t.equalsFunc(&other.(*tree).Tree, eq)
+}
 
-	if t != ot {
-		lgr.Println("Equals: t != ot; pointers are not equal;")
+//equalsFunc is EqualsFunc() for two Trees of the same type.
+func (t *Tree[K, V]) equalsFunc(ot *Tree[K, V], eq func(a, b V) bool) bool {
+	if t == ot || t.root == ot.root {
+		return true
+	}
+	if t.numEnts != ot.numEnts {
 		return false
 	}
+	if eq == nil {
+		eq = func(a, b V) bool { return defaultValEquals(a, b) }
+	}
 
-	//lgr.Printf("t.Equals(ot) t,%p == ot,%p", t, ot)
-
-	switch trn := t.root.(type) {
-	case *leafNodeS:
-		switch orn := ot.root.(type) {
-		case *leafNodeS:
-			if trn != orn {
-				lgr.Printf("t.root and other.rooth are both *leafNodeS, but not the same pointer; t.root=%p, other.root=%p", trn, orn)
-			} else {
-				//TRACING PRINT
-				//lgr.Printf("Equals: t.equals(tree.root, other.root, 0, 0) for calling t.root.(type),%T\n", trn)
-				return t.equals(trn, orn, 0, 0)
-			}
-		case *interiorNodeS:
-			lgr.Printf("t.root is *leafNodeS and other.root is *interiorNodeS")
-		default:
-			lgr.Printf("t.root is *leafNodeS and other.root is unknown=%T", orn)
+	ti := t.iter()
+	oi := ot.iter()
+	tok, ook := ti.Next(), oi.Next()
+	for tok && ook {
+		if skipShared(ti, oi) {
+			tok, ook = ti.valid(), oi.valid()
+			continue
 		}
-	case *interiorNodeS:
-		switch orn := ot.root.(type) {
-		case *leafNodeS:
-			lgr.Printf("t.root is *interiorNodeS & other.root is *leafNodeS")
-		case *interiorNodeS:
-			if trn != orn {
-				lgr.Printf("t.root and other.rooth are both *interiorNodeS, but not the same pointer; t.root=%p, other=%p", trn, orn)
-			} else {
-				//TRACING PRINT
-				//lgr.Printf("Equals: calling t.equals(tree.root, other.root, 0, 0) for t.root.(type),%T;\n", trn)
-				return t.equals(trn, orn, 0, 0)
-			}
-		default:
-			lgr.Printf("t.root is *interiorNodeS and other is unknown=%T", orn)
+		if t.cmp(ti.Key(), oi.Key()) != 0 || !eq(ti.Val(), oi.Val()) {
+			return false
 		}
-	default:
-		lgr.Printf("t.root unknown type=%T\n", trn)
+		tok, ook = ti.Next(), oi.Next()
 	}
 
-	return false
+	return tok == ook
 }
 
-func (t *tree) equals(tn, on nodeI, idx, depth int) bool {
-	//TRACING PRINT
-	//lgr.Printf("t.equals: idx=%d; depth=%d", idx, depth)
-	if tn.isLeaf() {
-		tnl := tn.(*leafNodeS)
-		onl := on.(*leafNodeS)
-		if len(tnl.keys) != len(onl.keys) {
-			lgr.Printf("len(tnl.keys),%d != len(onl.keys),%d\n", len(tnl.keys), len(onl.keys))
-			return false
-		}
-		if len(tnl.vals) != len(onl.vals) {
-			lgr.Printf("len(tnl.vals),%d != len(onl.vals),%d\n", len(tnl.vals), len(onl.vals))
-			return false
-		}
-		if len(tnl.keys) != len(tnl.vals) {
-			lgr.Println("tnl & onl are both *leafNodeS")
-			lgr.Printf("len(tnl.keys),%d != len(tnl.vals),%d\n", len(tnl.keys), len(tnl.vals))
-			return false
-		}
-		for i := range tnl.keys {
-			if !tnl.keys[i].Equals(onl.keys[i]) {
-				lgr.Printf("tnl.keys[%d],%q != onl.keys[%d],%q\n", i, tnl.keys[i], i, onl.keys[i])
-				return false
-			}
-		}
-		for i := range tnl.vals {
-			if tnl.vals[i] != onl.vals[i] {
-				lgr.Printf("tnl.vals[%d],%v != onl.vals[%d],%v\n", i, tnl.vals[i], i, onl.vals[i])
-				return false
-			}
-		}
-		//TRACING PRINT
-		//lgr.Printf("t.equals: tn.isLeaf: should return true")
-	} else {
-		tni := tn.(*interiorNodeS)
-		oni := on.(*interiorNodeS)
-		if len(tni.keys) != len(oni.keys) {
-			lgr.Printf("len(tni.keys),%d != len(oni.keys),%d\n", len(tni.keys), len(oni.keys))
-			return false
-		}
-		if len(tni.vals) != len(oni.vals) {
-			lgr.Printf("len(tni.vals),%d != len(oni.vals),%d\n", len(tni.vals), len(oni.vals))
-			return false
-		}
-		if len(tni.keys) != len(tni.vals)-1 {
-			lgr.Println("tni && oni are both *interiorNodeS")
-			lgr.Printf("len(tni.keys),%d != len(tni.vals)-1,%d\n", len(tni.keys), len(tni.vals)-1)
-			return false
-		}
-		for i := range tni.vals {
-			switch tnn := tni.vals[i].(type) {
-			case *leafNodeS:
-				switch onn := oni.vals[i].(type) {
-				case *leafNodeS:
-					if tnn != onn {
-						lgr.Printf("tni.vals[%d] and oni.vals[%d] are both *leafNodeS, but not the same pointer; tni.vals[%d]=%p, oni.vals[%d]=%p", i, i, i, tnn, i, onn)
-					} else {
-						//TRACING PRINT
-						//lgr.Printf("t.equals: *leafNodeS recursing i=%d depth+1=%d\n", i, depth+1)
-						return t.equals(tnn, onn, i, depth+1)
-					}
-				case *interiorNodeS:
-					lgr.Printf("tni.vals[%d] is *leafNodeS and oni.vals[%d] is *interiorNodeS", i, i)
-				default:
-					lgr.Printf("tni.vals[%d] is *leafNodeS and oni.vals[%d] is unknown=%T", i, i, onn)
-				}
-			case *interiorNodeS:
-				switch onn := oni.vals[i].(type) {
-				case *leafNodeS:
-					lgr.Printf("tni.vals[%d] is *interiorNodeS and oni.vals[%d] is *leafNodeS", i, i)
-				case *interiorNodeS:
-					if tnn != onn {
-						lgr.Printf("tni.vals[%d] and oni.vals[%d] are both *interiorNodeS, but not the same pointer; tni.vals[%d]=%p, oni.vals[%d]=%p", i, i, i, tnn, i, onn)
-					} else {
-						//TRACING PRINT
-						//lgr.Printf("t.equals: *interiorNodeS recursing i=%d depth+1=%d\n", i, depth+1)
-						return t.equals(tnn, onn, i, depth+1)
-					}
-				default:
-					lgr.Printf("tni.vals[%d] is *interiorNodeS and oni.vals[%d] is unknown=%T", i, i, onn)
-				}
-			default:
-				lgr.Printf("tni.vals[%d] unknown type=%T\n", i, tnn)
-			}
-		} //for i := range tni.vals
-	} // else //!tn.isLeaf()
-
-	//TRACING PRINT
-	//lgr.Printf("t.equals: returning true; idx=%d; depth=%d", idx, depth)
-	return true
-} // func (t *tree) equals(...) boola
+//defaultValEquals compares two values with ==, or with reflect.DeepEqual()
+//if they are not comparable.
+func defaultValEquals(a, b interface{}) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	va, vb := reflect.ValueOf(a), reflect.ValueOf(b)
+	if va.Type() != vb.Type() {
+		return false
+	}
+	if va.Comparable() && vb.Comparable() {
+		return a == b
+	}
+	return reflect.DeepEqual(a, b)
+}
 
 //Order returns the order of the *tree
 //
-func (t *tree) Order() int {
+func (t *Tree[K, V]) Order() int {
 	return t.order
 }
 
 //String creates a string representation of the *tree structure.
 //
-func (t *tree) String() string {
+func (t *Tree[K, V]) String() string {
 	s := ""
 	if t.root.isLeaf() {
-		rootLeaf := t.root.(*leafNodeS)
+		rootLeaf := t.root.(*leafNodeS[K, V])
 		s += fmt.Sprintf("TREE: root=%p; order=%d;\n", rootLeaf, t.order)
 		s += "\n"
 		s += fmt.Sprint(rootLeaf)
 	} else { // t.root is an interiorNodeS
-		rootNode := t.root.(*interiorNodeS)
+		rootNode := t.root.(*interiorNodeS[K, V])
 
 		s += fmt.Sprintf("TREE: root=%p; order=%d\n", rootNode, t.order)
 		s += "\n"
 		s += fmt.Sprint(rootNode)
 
-		nodes := make([]nodeI, 0, 2)
+		nodes := make([]nodeI[K, V], 0, 2)
 
 		//seed the nodes slice
 		for i := 0; i < len(rootNode.vals); i++ {
@@ -306,7 +307,7 @@ func (t *tree) String() string {
 		for i := 0; i < len(nodes); i++ {
 			s += fmt.Sprint(nodes[i])
 			if !nodes[i].isLeaf() {
-				tNode := nodes[i].(*interiorNodeS)
+				tNode := nodes[i].(*interiorNodeS[K, V])
 				nodes = append(nodes, tNode.vals...)
 			}
 		}
@@ -316,60 +317,206 @@ func (t *tree) String() string {
 
 //NumberOfEntries() returns the number of entries in the B+Tree.
 //
-func (t *tree) NumberOfEntries() int {
+func (t *Tree[K, V]) NumberOfEntries() int {
 	return t.numEnts
 }
 
 //Depth() returns the depth of the tree
 //
-func (t *tree) Depth() int {
+func (t *Tree[K, V]) Depth() int {
 	return t.depth
 }
 
 //Get(key) returns the value stored for key, and a boolean that indicates
 //if it was found or not.
 //
-func (t *tree) Get(key BptKey) (interface{}, bool) {
-	//Find a Leaf matching BptKey from the root of *tree
-	leaf, _ := t.findLeaf(key)
+func (t *Tree[K, V]) Get(key K) (V, bool) {
+	//Find a Leaf matching key from the root of *Tree; like findLeaf()
+	//without the path, so that Get() does not allocate
+	nextNode := t.root
+
+	for !nextNode.isLeaf() {
+		curNode := nextNode.(*interiorNodeS[K, V])
+		nextNode = curNode.vals[searchNode(curNode.keys, key, t.cmp)]
+	}
+
+	return nextNode.(*leafNodeS[K, V]).get(key, t.cmp)
+}
+
+//Min() returns the entry with the least key in the tree, and a boolean that
+//is false if the tree is empty.
+//
+func (t *Tree[K, V]) Min() (K, V, bool) {
+	leaf, _ := t.findLeftMostLeaf()
+	if len(leaf.keys) == 0 {
+		var zeroK K
+		var zeroV V
+		return zeroK, zeroV, false
+	}
+	return leaf.keys[0], leaf.vals[0], true
+}
+
+//Max() returns the entry with the greatest key in the tree, and a boolean
+//that is false if the tree is empty.
+//
+func (t *Tree[K, V]) Max() (K, V, bool) {
+	leaf, _ := t.findRightMostLeaf()
+	if len(leaf.keys) == 0 {
+		var zeroK K
+		var zeroV V
+		return zeroK, zeroV, false
+	}
+	last := len(leaf.keys) - 1
+	return leaf.keys[last], leaf.vals[last], true
+}
+
+//Floor(key) returns the entry with the greatest key less than or equal to
+//key, and a boolean that is false if there is no such entry.
+//
+func (t *Tree[K, V]) Floor(key K) (K, V, bool) {
+	var noKey K
+	return firstEntry(t.revRangeIter(noKey, false, false, key, true, true))
+}
+
+//Ceiling(key) returns the entry with the least key greater than or equal to
+//key, and a boolean that is false if there is no such entry.
+//
+func (t *Tree[K, V]) Ceiling(key K) (K, V, bool) {
+	var noKey K
+	return firstEntry(t.rangeIter(key, true, true, noKey, false, false))
+}
+
+//Lower(key) returns the entry with the greatest key strictly less than key,
+//and a boolean that is false if there is no such entry.
+//
+func (t *Tree[K, V]) Lower(key K) (K, V, bool) {
+	var noKey K
+	return firstEntry(t.revRangeIter(noKey, false, false, key, true, false))
+}
+
+//Higher(key) returns the entry with the least key strictly greater than key,
+//and a boolean that is false if there is no such entry.
+//
+func (t *Tree[K, V]) Higher(key K) (K, V, bool) {
+	var noKey K
+	return firstEntry(t.rangeIter(key, true, false, noKey, false, false))
+}
+
+//firstEntry returns the first entry of a range iterator. The range iterators
+//backtrack through the root-to-leaf path when the answer lives in a
+//neighbouring leaf of the one findLeaf() lands on.
+func firstEntry[K, V any](it *rangeIterS[K, V]) (K, V, bool) {
+	if !it.Next() {
+		var zeroK K
+		var zeroV V
+		return zeroK, zeroV, false
+	}
+	return it.Key(), it.Val(), true
+}
+
+//Rank(key) returns the number of entries in the tree whose keys are less
+//than key. If key is in the tree, that is its zero-based position in key
+//order. It runs in O(log n) time using the per-child entry counts kept in
+//every interior node.
+//
+func (t *Tree[K, V]) Rank(key K) int {
+	var rank int
+
+	nextNode := t.root
+
+	for !nextNode.isLeaf() {
+		curNode := nextNode.(*interiorNodeS[K, V])
+
+		i := searchNode(curNode.keys, key, t.cmp)
+		for _, cnt := range curNode.cnts[:i] {
+			rank += cnt
+		}
+		nextNode = curNode.vals[i]
+	}
+
+	leaf := nextNode.(*leafNodeS[K, V])
+	i, _ := searchLeaf(leaf.keys, key, t.cmp)
+	rank += i
+
+	return rank
+}
+
+//Select(i) returns the entry at zero-based position i in key order. It
+//returns nil, nil if i is not between 0 and NumberOfEntries()-1. It runs in
+//O(log n) time.
+//
+func (t *Tree[K, V]) Select(i int) (K, V) {
+	if i < 0 || i >= t.numEnts {
+		var zeroK K
+		var zeroV V
+		return zeroK, zeroV
+	}
+
+	nextNode := t.root
+
+	for !nextNode.isLeaf() {
+		curNode := nextNode.(*interiorNodeS[K, V])
 
-	for i, k := range leaf.keys {
-		if key.Equals(k) {
-			return leaf.vals[i], true
+		var j int
+		for j = 0; j < len(curNode.cnts)-1; j++ {
+			if i < curNode.cnts[j] {
+				break
+			}
+			i -= curNode.cnts[j]
 		}
+		nextNode = curNode.vals[j]
 	}
 
-	return nil, false
+	leaf := nextNode.(*leafNodeS[K, V])
+	return leaf.keys[i], leaf.vals[i]
 }
 
 //Put(key, val)
 //
 func (ot *tree) Put(key BptKey, val interface{}) (BpTree, bool) {
+	oldLeaf, path := ot.findLeaf(key)
+	if ot.isNoopPut(oldLeaf, key, val) {
+		return ot, false
+	}
+
 	t := ot.copy()
+	_, added := t.putLeaf(oldLeaf, path, key, val)
+	return t.freeze(), added
+}
 
+//put does the work of Put() on t itself; t must be a fresh copy of the
+//receiver of Put(), or the tree of a transient. It returns the value that
+//val replaced, if any, along with added.
+func (t *Tree[K, V]) put(key K, val V) (V, bool) {
 	oldLeaf, path := t.findLeaf(key)
+	return t.putLeaf(oldLeaf, path, key, val)
+}
 
-	newLeaf := oldLeaf.copy()
+//putLeaf is put() for callers that have already found oldLeaf, the leaf
+//for key, and the path to it.
+func (t *Tree[K, V]) putLeaf(oldLeaf *leafNodeS[K, V], path pathT[K, V], key K, val V) (V, bool) {
+	newLeaf := t.editLeaf(oldLeaf)
 
-	added := newLeaf.insert(key, val)
+	old, added := newLeaf.insert(key, val, t.cmp)
 	if added {
 		t.numEnts++
 	}
 
 	if newLeaf.isToBig() {
 		rightLeaf, rightKey := newLeaf.split()
+		rightLeaf.edit = t.edit
 		t.insertUpLeaf(oldLeaf, newLeaf, rightKey, rightLeaf, path)
 	} else {
 		t.copyUpLeaf(oldLeaf, newLeaf, path)
 	}
 
-	return t, added
+	return old, added
 }
 
-func (t *tree) insertUpLeaf(
-	oldLeaf, newLeaf *leafNodeS,
-	splitKey BptKey, splitLeaf *leafNodeS,
-	path pathT,
+func (t *Tree[K, V]) insertUpLeaf(
+	oldLeaf, newLeaf *leafNodeS[K, V],
+	splitKey K, splitLeaf *leafNodeS[K, V],
+	path pathT[K, V],
 ) {
 	if path.isEmpty() {
 		t.newRootNode(splitKey, newLeaf, splitLeaf)
@@ -377,27 +524,28 @@ func (t *tree) insertUpLeaf(
 	}
 
 	oldParent := path.pop()
-	newParent := oldParent.copy()
+	newParent := t.editNode(oldParent)
 
 	newParent.swapLeafNode(oldLeaf, newLeaf)
 
-	newParent.insert(splitKey, splitLeaf)
+	newParent.insert(splitKey, splitLeaf, t.cmp)
 
 	if newParent.isToBig() {
 		rightNode, rightKey := newParent.split()
+		rightNode.edit = t.edit
 		t.insertUp(oldParent, newParent, rightKey, rightNode, path)
 	} else {
 		t.copyUp(oldParent, newParent, path)
 	}
 }
 
-func (t *tree) insertUp(
-	oldNode, newNode *interiorNodeS,
-	splitKey BptKey, splitNode *interiorNodeS,
-	path pathT,
+func (t *Tree[K, V]) insertUp(
+	oldNode, newNode *interiorNodeS[K, V],
+	splitKey K, splitNode *interiorNodeS[K, V],
+	path pathT[K, V],
 ) {
 	if path.isEmpty() {
-		rootNode := t.root.(*interiorNodeS)
+		rootNode := t.root.(*interiorNodeS[K, V])
 		assert(rootNode == oldNode, "rootNode != oldNode")
 
 		t.newRootNode(splitKey, newNode, splitNode)
@@ -405,21 +553,22 @@ func (t *tree) insertUp(
 	}
 
 	oldParent := path.pop()
-	newParent := oldParent.copy()
+	newParent := t.editNode(oldParent)
 
 	newParent.swapInteriorNode(oldNode, newNode)
 
-	newParent.insert(splitKey, splitNode)
+	newParent.insert(splitKey, splitNode, t.cmp)
 
 	if newParent.isToBig() {
 		rightNode, rightKey := newParent.split()
+		rightNode.edit = t.edit
 		t.insertUp(oldParent, newParent, rightKey, rightNode, path)
 	} else {
 		t.copyUp(oldParent, newParent, path)
 	}
 }
 
-func (t *tree) copyUpLeaf(oldLeaf, newLeaf *leafNodeS, path pathT) {
+func (t *Tree[K, V]) copyUpLeaf(oldLeaf, newLeaf *leafNodeS[K, V], path pathT[K, V]) {
 	if path.isEmpty() {
 		assert(t.isRoot(oldLeaf), "!t.isRoot(oldLeaf)")
 		t.setRootLeaf(newLeaf)
@@ -427,14 +576,14 @@ func (t *tree) copyUpLeaf(oldLeaf, newLeaf *leafNodeS, path pathT) {
 	}
 
 	oldParent := path.pop()
-	newParent := oldParent.copy()
+	newParent := t.editNode(oldParent)
 
 	newParent.swapLeafNode(oldLeaf, newLeaf)
 
 	t.copyUp(oldParent, newParent, path)
 }
 
-func (t *tree) copyUp(oldNode, newNode *interiorNodeS, path pathT) {
+func (t *Tree[K, V]) copyUp(oldNode, newNode *interiorNodeS[K, V], path pathT[K, V]) {
 	if path.isEmpty() {
 		assert(t.isRoot(oldNode), "path.isEmpty() && !t.isRoot(oldNode)")
 		//WARNING: has additional check for shrinking the tree
@@ -443,7 +592,7 @@ func (t *tree) copyUp(oldNode, newNode *interiorNodeS, path pathT) {
 	}
 
 	oldParent := path.pop()
-	newParent := oldParent.copy()
+	newParent := t.editNode(oldParent)
 
 	newParent.swapInteriorNode(oldNode, newNode)
 
@@ -453,32 +602,48 @@ func (t *tree) copyUp(oldNode, newNode *interiorNodeS, path pathT) {
 func (ot *tree) Del(key BptKey) (BpTree, interface{}, bool) {
 	t := ot.copy()
 
+	val, removed := t.del(key)
+	if !removed {
+		return ot, val, removed
+	}
+
+	return t.freeze(), val, removed
+}
+
+//del does the work of Del() on t itself; t must be a fresh copy of the
+//receiver of Del(), or the tree of a transient.
+func (t *Tree[K, V]) del(key K) (V, bool) {
 	oldLeaf, path := t.findLeaf(key)
+	return t.delLeaf(oldLeaf, path, key)
+}
 
-	newLeaf := oldLeaf.copy()
+//delLeaf is del() for callers that have already found oldLeaf, the leaf for
+//key, and the path to it.
+func (t *Tree[K, V]) delLeaf(oldLeaf *leafNodeS[K, V], path pathT[K, V], key K) (V, bool) {
+	newLeaf := t.editLeaf(oldLeaf)
 
-	val, removed := newLeaf.remove(key)
+	val, removed := newLeaf.remove(key, t.cmp)
 	if !removed {
-		return ot, val, removed
+		return val, removed
 	}
 	// removed == true && val == val removed from here on
 
 	//keep t.numEnts up to date
 	t.numEnts--
 
-	if ot.isRoot(oldLeaf) {
-		assert(path.isEmpty(), "ot.isroot(oldLeaf) && !path.isEmpty()")
+	if t.isRoot(oldLeaf) {
+		assert(path.isEmpty(), "t.isroot(oldLeaf) && !path.isEmpty()")
 
 		//reuse first part of copyUpLeaf
 		t.copyUpLeaf(oldLeaf, newLeaf, path)
 
-		return t, val, removed
+		return val, removed
 	}
 	//ELSE !path.isEmpty()
 
 	if !newLeaf.isToSmall() { //aka newLeaf.size() >= newLeaf.halfFullSize()
 		t.copyUpLeaf(oldLeaf, newLeaf, path)
-		return t, val, removed
+		return val, removed
 	}
 	//ELSE newLeaf.isToSmall() from here after
 
@@ -488,18 +653,18 @@ func (ot *tree) Del(key BptKey) (BpTree, interface{}, bool) {
 	oldLeafLeft, leftKey := oldLeaf.findPeerLeft(oldParent)
 	if oldLeafLeft != nil {
 		if oldLeafLeft.size() > oldLeafLeft.halfFullSize() {
-			newLeftLeaf := oldLeafLeft.copy()
+			newLeftLeaf := t.editLeaf(oldLeafLeft)
 			newLeaf.stealLeft(newLeftLeaf)
 
-			newParent := oldParent.copy()
+			newParent := t.editNode(oldParent)
 
-			newParent.swapKey(leftKey, newLeaf.findLeftMostKey())
+			newParent.swapKey(leftKey, newLeaf.findLeftMostKey(), t.cmp)
 			newParent.swapLeafNode(oldLeafLeft, newLeftLeaf)
 			newParent.swapLeafNode(oldLeaf, newLeaf)
 
 			t.copyUp(oldParent, newParent, path)
 
-			return t, val, removed
+			return val, removed
 		}
 	}
 
@@ -507,31 +672,31 @@ func (ot *tree) Del(key BptKey) (BpTree, interface{}, bool) {
 	oldLeafRight, rightKey := oldLeaf.findPeerRight(oldParent)
 	if oldLeafRight != nil {
 		if oldLeafRight.size() > oldLeafRight.halfFullSize() {
-			newRightLeaf := oldLeafRight.copy()
+			newRightLeaf := t.editLeaf(oldLeafRight)
 			newLeaf.stealRight(newRightLeaf)
 
-			newParent := oldParent.copy()
+			newParent := t.editNode(oldParent)
 
-			newParent.swapKey(rightKey, newRightLeaf.findLeftMostKey())
+			newParent.swapKey(rightKey, newRightLeaf.findLeftMostKey(), t.cmp)
 			newParent.swapLeafNode(oldLeafRight, newRightLeaf)
 			newParent.swapLeafNode(oldLeaf, newLeaf)
 
 			t.copyUp(oldParent, newParent, path)
 
-			return t, val, removed
+			return val, removed
 		}
 	}
 
 	//merge
-	var oldMergedLeaf *leafNodeS
-	var newMergedLeaf *leafNodeS
-	var deadLeaf *leafNodeS
+	var oldMergedLeaf *leafNodeS[K, V]
+	var newMergedLeaf *leafNodeS[K, V]
+	var deadLeaf *leafNodeS[K, V]
 	if oldLeafLeft == nil && oldLeafRight == nil {
 		lgr.Panic("oldLeafLeft == nil && oldLeafRight == nil; should not be able to happend outside order==2 which we don't support.")
 	}
 	//else either or both leftLeaf&rightLeaf != nil
 	if oldLeafLeft != nil {
-		newLeafLeft := oldLeafLeft.copy()
+		newLeafLeft := t.editLeaf(oldLeafLeft)
 
 		//newLeaf got sucked into newLeafLeft so it will be gc'd
 		newLeafLeft.mergeRight(newLeaf)
@@ -549,19 +714,19 @@ func (ot *tree) Del(key BptKey) (BpTree, interface{}, bool) {
 
 	t.delUpLeaf(oldParent, oldMergedLeaf, newMergedLeaf, deadLeaf, path)
 
-	return t, val, removed
+	return val, removed
 }
 
-func (t *tree) updateInteriorParent(
-	oldParent *interiorNodeS,
-	oldSwapKey, newSwapKey BptKey,
+func (t *Tree[K, V]) updateInteriorParent(
+	oldParent *interiorNodeS[K, V],
+	oldSwapKey, newSwapKey K,
 	oldStolenNode, newStolenNode,
-	oldPrimaryNode, newPrimaryNode *interiorNodeS,
-	path pathT,
+	oldPrimaryNode, newPrimaryNode *interiorNodeS[K, V],
+	path pathT[K, V],
 ) {
-	newParent := oldParent.copy()
+	newParent := t.editNode(oldParent)
 
-	newParent.swapKey(oldSwapKey, newSwapKey)
+	newParent.swapKey(oldSwapKey, newSwapKey, t.cmp)
 
 	newParent.swapInteriorNode(oldStolenNode, newStolenNode)
 	newParent.swapInteriorNode(oldPrimaryNode, newStolenNode)
@@ -569,12 +734,12 @@ func (t *tree) updateInteriorParent(
 	t.copyUp(oldParent, newParent, path)
 }
 
-func (t *tree) delUpLeaf(
-	oldParent *interiorNodeS,
-	oldMergedLeaf, newMergedLeaf, deadLeaf *leafNodeS,
-	path pathT,
+func (t *Tree[K, V]) delUpLeaf(
+	oldParent *interiorNodeS[K, V],
+	oldMergedLeaf, newMergedLeaf, deadLeaf *leafNodeS[K, V],
+	path pathT[K, V],
 ) {
-	newParent := oldParent.copy()
+	newParent := t.editNode(oldParent)
 
 	//Replace oldMergedLeaf with newMergedLeaf
 	newParent.swapLeafNode(oldMergedLeaf, newMergedLeaf)
@@ -587,8 +752,7 @@ func (t *tree) delUpLeaf(
 			if i == 0 {
 				lgr.Panic("delUpLeaf: oldMergeLeaf,%p was before deadLeaf,%p by definition in t.Del(); oldParent=\n%vnewParent=\n%v", oldMergedLeaf, deadLeaf, oldParent, newParent)
 			}
-			newParent.keys = append(newParent.keys[:i-1], newParent.keys[i:]...)
-			newParent.vals = append(newParent.vals[:i], newParent.vals[i+1:]...)
+			newParent.removeAt(i)
 
 			break //guaranteed i != orgLen
 		}
@@ -639,12 +803,12 @@ func (t *tree) delUpLeaf(
 	if oldPeerLeft != nil {
 		if oldPeerLeft.size() > oldPeerLeft.halfFullSize() {
 			//leftPeer is big enough to steal from
-			newPeerLeft := oldPeerLeft.copy()
+			newPeerLeft := t.editNode(oldPeerLeft)
 			newParent.stealLeft(newPeerLeft)
 
-			newGrandParent := oldGrandParent.copy()
+			newGrandParent := t.editNode(oldGrandParent)
 
-			newGrandParent.swapKey(leftKey, newParent.findLeftMostKey())
+			newGrandParent.swapKey(leftKey, newParent.findLeftMostKey(), t.cmp)
 			newGrandParent.swapInteriorNode(oldPeerLeft, newPeerLeft)
 			newGrandParent.swapInteriorNode(oldParent, newParent)
 
@@ -658,12 +822,12 @@ func (t *tree) delUpLeaf(
 	if oldPeerRight != nil {
 		if oldPeerRight.size() > oldPeerRight.halfFullSize() {
 			//rightPeer is big enough to steal from
-			newPeerRight := oldPeerRight.copy()
+			newPeerRight := t.editNode(oldPeerRight)
 			newParent.stealRight(newPeerRight)
 
-			newGrandParent := oldGrandParent.copy()
+			newGrandParent := t.editNode(oldGrandParent)
 
-			newGrandParent.swapKey(rightKey, newPeerRight.findLeftMostKey())
+			newGrandParent.swapKey(rightKey, newPeerRight.findLeftMostKey(), t.cmp)
 			newGrandParent.swapInteriorNode(oldPeerRight, newPeerRight)
 			newGrandParent.swapInteriorNode(oldParent, newParent)
 
@@ -675,14 +839,14 @@ func (t *tree) delUpLeaf(
 	//ELSE either oldPeerLeft & oldPeerRight != nil
 
 	//merge
-	var oldMergedNode *interiorNodeS
-	var newMergedNode *interiorNodeS
-	var deadNode *interiorNodeS
+	var oldMergedNode *interiorNodeS[K, V]
+	var newMergedNode *interiorNodeS[K, V]
+	var deadNode *interiorNodeS[K, V]
 	if oldPeerLeft == nil && oldPeerRight == nil {
 		lgr.Panic("oldPeerLeft == nil && oldPeerRight == nil; should not be able to heppen outside order=2 which we don't support")
 	}
 	if oldPeerLeft != nil {
-		newPeerLeft := oldPeerLeft.copy()
+		newPeerLeft := t.editNode(oldPeerLeft)
 		newPeerLeft.mergeRight(newParent)
 		oldMergedNode = oldPeerLeft
 		newMergedNode = newPeerLeft
@@ -700,12 +864,12 @@ func (t *tree) delUpLeaf(
 	t.delUp(oldGrandParent, oldMergedNode, newMergedNode, deadNode, path)
 }
 
-func (t *tree) delUp(
-	oldParent *interiorNodeS,
-	oldMergedNode, newMergedNode, deadNode *interiorNodeS,
-	path pathT,
+func (t *Tree[K, V]) delUp(
+	oldParent *interiorNodeS[K, V],
+	oldMergedNode, newMergedNode, deadNode *interiorNodeS[K, V],
+	path pathT[K, V],
 ) {
-	newParent := oldParent.copy()
+	newParent := t.editNode(oldParent)
 
 	//Replace oldMergedNode with newMergedNode
 	newParent.swapInteriorNode(oldMergedNode, newMergedNode)
@@ -718,8 +882,7 @@ func (t *tree) delUp(
 			if i == 0 {
 				lgr.Panic("delUp: oldMergedNode,%p was before deadNode,%p by definition in t.Del(); oldParent=\n%vnewParent=\n%v", oldMergedNode, deadNode, oldParent, newParent)
 			}
-			newParent.keys = append(newParent.keys[:i-1], newParent.keys[i:]...)
-			newParent.vals = append(newParent.vals[:i], newParent.vals[i+1:]...)
+			newParent.removeAt(i)
 
 			break
 		}
@@ -754,12 +917,12 @@ func (t *tree) delUp(
 	if oldPeerLeft != nil {
 		if oldPeerLeft.size() > oldPeerLeft.halfFullSize() {
 			//leftPeer is big enough to steal from
-			newPeerLeft := oldPeerLeft.copy()
+			newPeerLeft := t.editNode(oldPeerLeft)
 			newParent.stealLeft(newPeerLeft)
 
-			newGrandParent := oldGrandParent.copy()
+			newGrandParent := t.editNode(oldGrandParent)
 
-			newGrandParent.swapKey(leftKey, newParent.findLeftMostKey())
+			newGrandParent.swapKey(leftKey, newParent.findLeftMostKey(), t.cmp)
 			newGrandParent.swapInteriorNode(oldPeerLeft, newPeerLeft)
 			newGrandParent.swapInteriorNode(oldParent, newParent)
 
@@ -773,12 +936,12 @@ func (t *tree) delUp(
 	if oldPeerRight != nil {
 		if oldPeerRight.size() > oldPeerRight.halfFullSize() {
 			//rightPeer is big enough to steal from
-			newPeerRight := oldPeerRight.copy()
+			newPeerRight := t.editNode(oldPeerRight)
 			newParent.stealRight(newPeerRight)
 
-			newGrandParent := oldGrandParent.copy()
+			newGrandParent := t.editNode(oldGrandParent)
 
-			newGrandParent.swapKey(rightKey, newPeerRight.findLeftMostKey())
+			newGrandParent.swapKey(rightKey, newPeerRight.findLeftMostKey(), t.cmp)
 			newGrandParent.swapInteriorNode(oldPeerRight, newPeerRight)
 			newGrandParent.swapInteriorNode(oldParent, newParent)
 
@@ -791,14 +954,14 @@ func (t *tree) delUp(
 
 	//merge
 	//can not use oldMergedNode, newMergedNode, and deadNode variable names
-	var oldMNode *interiorNodeS
-	var newMNode *interiorNodeS
-	var dNode *interiorNodeS
+	var oldMNode *interiorNodeS[K, V]
+	var newMNode *interiorNodeS[K, V]
+	var dNode *interiorNodeS[K, V]
 	if oldPeerLeft == nil && oldPeerRight == nil {
 		lgr.Panic("oldPeerLeft == nil && oldPeerRight == nil; should not be able to heppen outside order=2 which we don't support")
 	}
 	if oldPeerLeft != nil {
-		newPeerLeft := oldPeerLeft.copy()
+		newPeerLeft := t.editNode(oldPeerLeft)
 		newPeerLeft.mergeRight(newParent)
 		oldMNode = oldPeerLeft
 		newMNode = newPeerLeft
@@ -816,69 +979,60 @@ func (t *tree) delUp(
 	t.delUp(oldGrandParent, oldMNode, newMNode, dNode, path)
 }
 
-func (t *tree) Graph() string {
+func (t *Tree[K, V]) Graph() string {
 	return ""
 }
 
-func (t *tree) isRoot(node nodeI) bool {
+func (t *Tree[K, V]) isRoot(node nodeI[K, V]) bool {
 	return t.root.equals(node)
 }
 
-func (t *tree) findLeaf(key BptKey) (*leafNodeS, pathT) {
-	path := newPathT()
+func (t *Tree[K, V]) findLeaf(key K) (*leafNodeS[K, V], pathT[K, V]) {
+	path := newPathT[K, V]()
 
 	nextNode := t.root
 
 	for !nextNode.isLeaf() {
-		curNode := nextNode.(*interiorNodeS)
+		curNode := nextNode.(*interiorNodeS[K, V])
 
 		path.push(curNode)
-		var i int
-		for i = 0; i < len(curNode.keys); i++ {
-			if key.LessThan(curNode.keys[i]) {
-				nextNode = curNode.vals[i]
-				break // guaranteed i != len(curNode.keys)
-			}
-		}
-		if i == len(curNode.keys) {
-			nextNode = curNode.vals[i]
-		}
+		nextNode = curNode.vals[searchNode(curNode.keys, key, t.cmp)]
 	}
 
-	return nextNode.(*leafNodeS), path
+	return nextNode.(*leafNodeS[K, V]), path
 }
 
-//func (t *tree) findLeftMostLeaf() (*leafNodeS, pathT) {
-//	path := newPathT()
-//
-//	nextNode := t.root
-//
-//	for !nextNode.isLeaf() {
-//		curNode := nextNode.(*interiorNodeS)
-//		path.push(curNode)
-//
-//		nextNode = curNode.vals[0]
-//	}
-//
-//	return nextNode.(*leafNodeS), path
-//}
+func (t *Tree[K, V]) findLeftMostLeaf() (*leafNodeS[K, V], pathT[K, V]) {
+	path := newPathT[K, V]()
+
+	nextNode := t.root
+
+	for !nextNode.isLeaf() {
+		curNode := nextNode.(*interiorNodeS[K, V])
+		path.push(curNode)
+
+		nextNode = curNode.vals[0]
+	}
+
+	return nextNode.(*leafNodeS[K, V]), path
+}
 
-func (t *tree) findRightMostLeaf() (*leafNodeS, pathT) {
-	path := newPathT()
+func (t *Tree[K, V]) findRightMostLeaf() (*leafNodeS[K, V], pathT[K, V]) {
+	path := newPathT[K, V]()
 
 	nextNode := t.root
 
 	for !nextNode.isLeaf() {
-		curNode := nextNode.(*interiorNodeS)
+		curNode := nextNode.(*interiorNodeS[K, V])
 		path.push(curNode)
 
 		nextNode = curNode.vals[len(curNode.vals)-1]
 	}
 
-	return nextNode.(*leafNodeS), path
+	return nextNode.(*leafNodeS[K, V]), path
 }
 
-func (t *tree) findLastKey() (BptKey, pathT) {
+func (t *Tree[K, V]) findLastKey() (K, pathT[K, V]) {
 	leaf, path := t.findRightMostLeaf()
 
 	lastKey := leaf.keys[len(leaf.keys)-1]
@@ -1,10 +1,16 @@
 package bptree
 
 import (
+	"bytes"
+	"fmt"
 	"math"
 	"math/rand"
 	"os"
+	"runtime"
+	"sort"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/lleo/util"
 )
@@ -228,6 +234,1972 @@ func TestRandomPutAndDelWithOrderThreeToSixtyFour(t *testing.T) {
 	}
 }
 
+func TestRandomPutWithInOrderIter(t *testing.T) {
+	for _, order := range []int{3, 4, 7, 32} {
+		bpt := NewBpTree(order)
+		for _, ent := range genRandomizedEntries(midNumEnts) {
+			bpt, _ = bpt.Put(ent.key, ent.val)
+		}
+
+		var i int
+		it := bpt.Iter()
+		for it.Next() {
+			if i >= len(midNumEnts) {
+				t.Fatalf("order=%d: iterator returned more than %d entries", order, len(midNumEnts))
+			}
+			if !it.Key().Equals(midNumEnts[i].key) || it.Val() != midNumEnts[i].val {
+				t.Fatalf("order=%d: entry %d = {%q %v}; expected {%q %v}", order, i, it.Key(), it.Val(), midNumEnts[i].key, midNumEnts[i].val)
+			}
+			i++
+		}
+		if i != len(midNumEnts) {
+			t.Fatalf("order=%d: iterator returned %d entries; expected %d", order, i, len(midNumEnts))
+		}
+		if it.Next() {
+			t.Fatalf("order=%d: Next() returned true after the iterator was exhausted", order)
+		}
+	}
+}
+
+func TestIterEmptyTree(t *testing.T) {
+	it := NewBpTree(3).Iter()
+	if it.Next() {
+		t.Fatalf("Next() on an empty tree returned true")
+	}
+	if it.Key() != nil || it.Val() != nil {
+		t.Fatalf("Key()/Val() on an exhausted iterator should be nil")
+	}
+}
+
+func TestIterIsUnaffectedByLaterVersions(t *testing.T) {
+	bpt := NewBpTree(3)
+	for _, ent := range midNumEnts {
+		bpt, _ = bpt.Put(ent.key, ent.val)
+	}
+
+	it := bpt.Iter()
+	nbpt := bpt
+	var i int
+	for it.Next() {
+		if !it.Key().Equals(midNumEnts[i].key) || it.Val() != midNumEnts[i].val {
+			t.Fatalf("entry %d = {%q %v}; expected {%q %v}", i, it.Key(), it.Val(), midNumEnts[i].key, midNumEnts[i].val)
+		}
+		//mutate derived versions while walking the original
+		nbpt, _, _ = nbpt.Del(midNumEnts[i].key)
+		nbpt, _ = nbpt.Put(StringKey("zz"+midNumEnts[i].key.String()), -1)
+		i++
+	}
+	if i != len(midNumEnts) {
+		t.Fatalf("iterator returned %d entries; expected %d", i, len(midNumEnts))
+	}
+}
+
+func TestRangeWithInclusiveAndExclusiveBounds(t *testing.T) {
+	//put every other entry so some bounds are not in the tree
+	bpt := NewBpTree(4)
+	var ents []entry
+	for i := 0; i < len(midNumEnts); i += 2 {
+		ents = append(ents, midNumEnts[i])
+	}
+	for _, ent := range genRandomizedEntries(ents) {
+		bpt, _ = bpt.Put(ent.key, ent.val)
+	}
+
+	for n := 0; n < 200; n++ {
+		loIdx := rand.Intn(len(midNumEnts))
+		hiIdx := loIdx + rand.Intn(len(midNumEnts)-loIdx)
+		lo, hi := midNumEnts[loIdx].key, midNumEnts[hiIdx].key
+		loInc, hiInc := rand.Intn(2) == 0, rand.Intn(2) == 0
+		var loKey, hiKey BptKey = lo, hi
+		if n%10 == 0 {
+			loKey = nil
+		}
+		if n%10 == 1 {
+			hiKey = nil
+		}
+
+		var expected []entry
+		for _, ent := range ents {
+			if loKey != nil && (ent.key.LessThan(lo) || (!loInc && ent.key.Equals(lo))) {
+				continue
+			}
+			if hiKey != nil && (hi.LessThan(ent.key) || (!hiInc && ent.key.Equals(hi))) {
+				continue
+			}
+			expected = append(expected, ent)
+		}
+
+		var i int
+		it := bpt.Range(loKey, loInc, hiKey, hiInc)
+		for it.Next() {
+			if i >= len(expected) {
+				t.Fatalf("Range(%v, %v, %v, %v) returned more than %d entries", loKey, loInc, hiKey, hiInc, len(expected))
+			}
+			if !it.Key().Equals(expected[i].key) || it.Val() != expected[i].val {
+				t.Fatalf("Range(%v, %v, %v, %v) entry %d = {%q %v}; expected {%q %v}", loKey, loInc, hiKey, hiInc, i, it.Key(), it.Val(), expected[i].key, expected[i].val)
+			}
+			i++
+		}
+		if i != len(expected) {
+			t.Fatalf("Range(%v, %v, %v, %v) returned %d entries; expected %d", loKey, loInc, hiKey, hiInc, i, len(expected))
+		}
+
+		i = len(expected)
+		it = bpt.RevRange(loKey, loInc, hiKey, hiInc)
+		for it.Next() {
+			i--
+			if i < 0 {
+				t.Fatalf("RevRange(%v, %v, %v, %v) returned more than %d entries", loKey, loInc, hiKey, hiInc, len(expected))
+			}
+			if !it.Key().Equals(expected[i].key) || it.Val() != expected[i].val {
+				t.Fatalf("RevRange(%v, %v, %v, %v) entry %d = {%q %v}; expected {%q %v}", loKey, loInc, hiKey, hiInc, i, it.Key(), it.Val(), expected[i].key, expected[i].val)
+			}
+		}
+		if i != 0 {
+			t.Fatalf("RevRange(%v, %v, %v, %v) returned %d entries; expected %d", loKey, loInc, hiKey, hiInc, len(expected)-i, len(expected))
+		}
+	}
+}
+
+func TestRangeEmptyResults(t *testing.T) {
+	bpt := NewBpTree(3)
+	for _, ent := range midNumEnts[:100] {
+		bpt, _ = bpt.Put(ent.key, ent.val)
+	}
+	k := midNumEnts[50].key
+	if bpt.Range(k, false, k, true).Next() {
+		t.Fatalf("Range(k, false, k, true) should be empty")
+	}
+	if bpt.Range(k, true, k, false).Next() {
+		t.Fatalf("Range(k, true, k, false) should be empty")
+	}
+	if it := bpt.Range(k, true, k, true); !it.Next() || !it.Key().Equals(k) || it.Next() {
+		t.Fatalf("Range(k, true, k, true) should contain exactly k")
+	}
+	if bpt.Range(midNumEnts[99].key, false, nil, false).Next() {
+		t.Fatalf("Range() past the last key should be empty")
+	}
+	if NewBpTree(3).Range(nil, false, nil, false).Next() {
+		t.Fatalf("Range() on an empty tree should be empty")
+	}
+}
+
+func TestRandomPutWithReverseIter(t *testing.T) {
+	for _, order := range []int{3, 4, 7, 32} {
+		bpt := NewBpTree(order)
+		for _, ent := range genRandomizedEntries(midNumEnts) {
+			bpt, _ = bpt.Put(ent.key, ent.val)
+		}
+
+		i := len(midNumEnts)
+		it := bpt.RevIter()
+		nbpt := bpt
+		for it.Next() {
+			i--
+			if i < 0 {
+				t.Fatalf("order=%d: reverse iterator returned more than %d entries", order, len(midNumEnts))
+			}
+			if !it.Key().Equals(midNumEnts[i].key) || it.Val() != midNumEnts[i].val {
+				t.Fatalf("order=%d: entry %d = {%q %v}; expected {%q %v}", order, i, it.Key(), it.Val(), midNumEnts[i].key, midNumEnts[i].val)
+			}
+			//mutate derived versions while walking the original
+			nbpt, _, _ = nbpt.Del(midNumEnts[i].key)
+		}
+		if i != 0 {
+			t.Fatalf("order=%d: reverse iterator returned %d entries; expected %d", order, len(midNumEnts)-i, len(midNumEnts))
+		}
+		if !nbpt.IsEmpty() {
+			t.Fatalf("order=%d: derived version should be empty", order)
+		}
+	}
+
+	if NewBpTree(3).RevIter().Next() {
+		t.Fatalf("RevIter().Next() on an empty tree returned true")
+	}
+}
+
+func TestMinMaxFloorCeilingLowerHigher(t *testing.T) {
+	bpt := NewBpTree(3)
+	if _, _, ok := bpt.Min(); ok {
+		t.Fatalf("Min() on an empty tree returned ok=true")
+	}
+	if _, _, ok := bpt.Max(); ok {
+		t.Fatalf("Max() on an empty tree returned ok=true")
+	}
+	if _, _, ok := bpt.Floor(midNumEnts[0].key); ok {
+		t.Fatalf("Floor() on an empty tree returned ok=true")
+	}
+
+	//put every other entry; the odd entries are the "missing" keys
+	for i := 0; i < len(midNumEnts); i += 2 {
+		bpt, _ = bpt.Put(midNumEnts[i].key, midNumEnts[i].val)
+	}
+	last := len(midNumEnts) - 1
+	if last%2 == 1 {
+		last--
+	}
+
+	check := func(name string, k BptKey, v interface{}, ok bool, idx int) {
+		if idx < 0 || idx > last {
+			if ok {
+				t.Fatalf("%s returned {%q %v}; expected no entry", name, k, v)
+			}
+			return
+		}
+		if !ok || !k.Equals(midNumEnts[idx].key) || v != midNumEnts[idx].val {
+			t.Fatalf("%s returned {%v %v %v}; expected {%q %v}", name, k, v, ok, midNumEnts[idx].key, midNumEnts[idx].val)
+		}
+	}
+
+	k, v, ok := bpt.Min()
+	check("Min()", k, v, ok, 0)
+	k, v, ok = bpt.Max()
+	check("Max()", k, v, ok, last)
+
+	for i, ent := range midNumEnts {
+		var floor, ceiling, lower, higher int
+		if i%2 == 0 {
+			floor, ceiling = i, i
+			lower, higher = i-2, i+2
+		} else {
+			floor, ceiling = i-1, i+1
+			lower, higher = i-1, i+1
+		}
+		k, v, ok = bpt.Floor(ent.key)
+		check("Floor()", k, v, ok, floor)
+		k, v, ok = bpt.Ceiling(ent.key)
+		check("Ceiling()", k, v, ok, ceiling)
+		k, v, ok = bpt.Lower(ent.key)
+		check("Lower()", k, v, ok, lower)
+		k, v, ok = bpt.Higher(ent.key)
+		check("Higher()", k, v, ok, higher)
+	}
+}
+
+func TestRankAndSelect(t *testing.T) {
+	for _, order := range []int{3, 4, 5, 8, 32} {
+		bpt := NewBpTree(order)
+		var versions []BpTree
+		for _, ent := range genRandomizedEntries(midNumEnts) {
+			bpt, _ = bpt.Put(ent.key, ent.val)
+			if rand.Intn(1000) == 0 {
+				versions = append(versions, bpt)
+			}
+		}
+		if !validTree(&bpt.(*tree).Tree) {
+			t.Fatalf("order=%d: invalid tree after Put()s", order)
+		}
+		for i, ent := range midNumEnts {
+			if r := bpt.Rank(ent.key); r != i {
+				t.Fatalf("order=%d: Rank(%q) = %d; expected %d", order, ent.key, r, i)
+			}
+			k, v := bpt.Select(i)
+			if k == nil || !k.Equals(ent.key) || v != ent.val {
+				t.Fatalf("order=%d: Select(%d) = {%v %v}; expected {%q %v}", order, i, k, v, ent.key, ent.val)
+			}
+		}
+		if k, v := bpt.Select(len(midNumEnts)); k != nil || v != nil {
+			t.Fatalf("order=%d: Select(NumberOfEntries()) should return nil, nil", order)
+		}
+		if r := bpt.Rank(StringKey("zzzzzzzz")); r != len(midNumEnts) {
+			t.Fatalf("order=%d: Rank() past the last key = %d; expected %d", order, r, len(midNumEnts))
+		}
+
+		//delete every other entry and check the ranks of the remaining ones
+		for i := 0; i < len(midNumEnts); i += 2 {
+			bpt, _, _ = bpt.Del(midNumEnts[i].key)
+		}
+		if !validTree(&bpt.(*tree).Tree) {
+			t.Fatalf("order=%d: invalid tree after Del()s", order)
+		}
+		for i := 1; i < len(midNumEnts); i += 2 {
+			if r := bpt.Rank(midNumEnts[i].key); r != i/2 {
+				t.Fatalf("order=%d: Rank(%q) = %d; expected %d", order, midNumEnts[i].key, r, i/2)
+			}
+			if r := bpt.Rank(midNumEnts[i-1].key); r != i/2 {
+				t.Fatalf("order=%d: Rank(%q) of a deleted key = %d; expected %d", order, midNumEnts[i-1].key, r, i/2)
+			}
+		}
+
+		//older versions keep their own counts
+		for _, v := range versions {
+			if !validTree(&v.(*tree).Tree) {
+				t.Fatalf("order=%d: an older version became invalid", order)
+			}
+		}
+	}
+}
+
+func TestGenericTreePutGetDel(t *testing.T) {
+	for _, order := range []int{3, 4, 5, 16} {
+		gt := NewTree[int, string](order)
+		model := make(map[int]string)
+		var versions []*Tree[int, string]
+		var models []map[int]string
+
+		for n := 0; n < 20000; n++ {
+			k := rand.Intn(2000)
+			if rand.Intn(3) == 0 {
+				var val string
+				var removed bool
+				gt, val, removed = gt.Del(k)
+				mval, ok := model[k]
+				if removed != ok || val != mval {
+					t.Fatalf("order=%d: Del(%d) = %q, %v; expected %q, %v", order, k, val, removed, mval, ok)
+				}
+				delete(model, k)
+			} else {
+				v := string(rune('a' + n%26))
+				var added bool
+				gt, added = gt.Put(k, v)
+				if _, ok := model[k]; added == ok {
+					t.Fatalf("order=%d: Put(%d) added=%v; expected %v", order, k, added, !ok)
+				}
+				model[k] = v
+			}
+			if n%2000 == 0 {
+				m := make(map[int]string, len(model))
+				for k, v := range model {
+					m[k] = v
+				}
+				versions = append(versions, gt)
+				models = append(models, m)
+			}
+		}
+		versions = append(versions, gt)
+		models = append(models, model)
+
+		for i, v := range versions {
+			if v.NumberOfEntries() != len(models[i]) {
+				t.Fatalf("order=%d: version %d NumberOfEntries()=%d; expected %d", order, i, v.NumberOfEntries(), len(models[i]))
+			}
+			for k, mv := range models[i] {
+				if gv, ok := v.Get(k); !ok || gv != mv {
+					t.Fatalf("order=%d: version %d Get(%d) = %q, %v; expected %q", order, i, k, gv, ok, mv)
+				}
+			}
+			if !validTree(v) {
+				t.Fatalf("order=%d: version %d is not a valid tree", order, i)
+			}
+		}
+	}
+}
+
+func TestGenericTreeEqualsAndCompareFunc(t *testing.T) {
+	cmpBytes := func(a, b []byte) int { return bytes.Compare(a, b) }
+	t0 := NewTreeFunc[[]byte, int](4, cmpBytes)
+	t1 := NewTreeFunc[[]byte, int](4, cmpBytes)
+	for i, ent := range midNumEnts {
+		t0, _ = t0.Put([]byte(ent.key.String()), ent.val)
+		rent := midNumEnts[len(midNumEnts)-1-i]
+		t1, _ = t1.Put([]byte(rent.key.String()), rent.val)
+	}
+	if !t0.Equals(t1, nil) {
+		t.Fatalf("trees built in different orders should be Equals()")
+	}
+	t2, _ := t1.Put([]byte(midNumEnts[7].key.String()), -1)
+	if t0.Equals(t2, nil) {
+		t.Fatalf("trees with different values should not be Equals()")
+	}
+	if !t2.Equals(t2, func(a, b int) bool { return false }) {
+		t.Fatalf("a tree should always be Equals() to itself")
+	}
+	if v, ok := t0.Get([]byte(midNumEnts[7].key.String())); !ok || v != midNumEnts[7].val {
+		t.Fatalf("Get() with a compare function returned %v, %v", v, ok)
+	}
+
+	var i int
+	var prev []byte
+	for it := t0.Iter(); it.Next(); i++ {
+		if i > 0 && bytes.Compare(prev, it.Key()) >= 0 {
+			t.Fatalf("Iter() entry %d %q is not after %q", i, it.Key(), prev)
+		}
+		if v, _ := t0.Get(it.Key()); it.Val() != v {
+			t.Fatalf("Iter() entry %d = {%q %v}; Get() returned %v", i, it.Key(), it.Val(), v)
+		}
+		prev = it.Key()
+	}
+	if i != len(midNumEnts) {
+		t.Fatalf("Iter() returned %d entries; expected %d", i, len(midNumEnts))
+	}
+}
+
+func TestGenericTreeEqualsWithUncomparableValues(t *testing.T) {
+	a := NewTree[int, []int](4)
+	b := NewTree[int, []int](4)
+	for i := 0; i < 100; i++ {
+		a, _ = a.Put(i, []int{i})
+		b, _ = b.Put(i, []int{i})
+	}
+	if !a.Equals(b, nil) {
+		t.Fatalf("trees with equal slice values should be Equals()")
+	}
+	b, _ = b.Put(50, []int{-1})
+	if a.Equals(b, nil) {
+		t.Fatalf("trees with different slice values should not be Equals()")
+	}
+	if c, _, _ := a.Del(1000); c != a {
+		t.Fatalf("Del() of a missing key should return the receiver")
+	}
+}
+
+func TestGenericTreeGetDoesNotAllocate(t *testing.T) {
+	gt := NewTree[int, int](32)
+	for i := 0; i < 10000; i++ {
+		gt, _ = gt.Put(i, i)
+	}
+	var k int
+	allocs := testing.AllocsPerRun(1000, func() {
+		if v, ok := gt.Get(k); !ok || v != k {
+			t.Fatalf("Get(%d) = %d, %v", k, v, ok)
+		}
+		k = (k + 7) % 10000
+	})
+	if allocs != 0 {
+		t.Fatalf("Get() made %v allocations; expected 0", allocs)
+	}
+}
+
+//entsIter is a BptIter over a slice of entries.
+type entsIter struct {
+	ents []entry
+	idx  int
+}
+
+func (it *entsIter) Next() bool {
+	it.idx++
+	return it.idx <= len(it.ents)
+}
+
+func (it *entsIter) Key() BptKey {
+	return it.ents[it.idx-1].key
+}
+
+func (it *entsIter) Val() interface{} {
+	return it.ents[it.idx-1].val
+}
+
+func TestBuildFromSorted(t *testing.T) {
+	for _, order := range []int{3, 4, 5, 7, 8, 32, 64} {
+		for _, fill := range []float64{0.1, 0.5, 0.75, 1} {
+			for _, n := range []int{0, 1, order - 1, order, order * order, len(midNumEnts)} {
+				bpt, err := BuildFromSorted(order, fill, &entsIter{ents: midNumEnts[:n]})
+				if err != nil {
+					t.Fatalf("order=%d; fill=%v; n=%d: BuildFromSorted() returned err=%v", order, fill, n, err)
+				}
+				if !validTree(&bpt.(*tree).Tree) {
+					t.Fatalf("order=%d; fill=%v; n=%d: BuildFromSorted() built an invalid tree", order, fill, n)
+				}
+				if bpt.NumberOfEntries() != n {
+					t.Fatalf("order=%d; fill=%v; n=%d: NumberOfEntries()=%d", order, fill, n, bpt.NumberOfEntries())
+				}
+				var i int
+				for it := bpt.Iter(); it.Next(); i++ {
+					if !it.Key().Equals(midNumEnts[i].key) || it.Val() != midNumEnts[i].val {
+						t.Fatalf("order=%d; fill=%v; n=%d: entry %d = {%q %v}", order, fill, n, i, it.Key(), it.Val())
+					}
+				}
+				if i != n {
+					t.Fatalf("order=%d; fill=%v; n=%d: iterated over %d entries", order, fill, n, i)
+				}
+			}
+		}
+	}
+
+	//the built tree must behave like any other
+	bpt, _ := BuildFromSorted(5, 1, &entsIter{ents: midNumEnts})
+	for _, ent := range genRandomizedEntries(midNumEnts) {
+		var found bool
+		bpt, _, found = bpt.Del(ent.key)
+		if !found {
+			t.Fatalf("Del(%q) on a built tree did not find the key", ent.key)
+		}
+	}
+	if !bpt.IsEmpty() {
+		t.Fatalf("built tree is not empty after deleting every key")
+	}
+}
+
+func TestBuildFromSortedRejectsBadInput(t *testing.T) {
+	ents := append([]entry(nil), midNumEnts[:100]...)
+	ents[50], ents[51] = ents[51], ents[50]
+	if _, err := BuildFromSorted(4, 1, &entsIter{ents: ents}); err == nil {
+		t.Fatalf("BuildFromSorted() accepted unsorted input")
+	}
+
+	ents = append([]entry(nil), midNumEnts[:100]...)
+	ents[51] = ents[50]
+	if _, err := BuildFromSorted(4, 1, &entsIter{ents: ents}); err == nil {
+		t.Fatalf("BuildFromSorted() accepted duplicate keys")
+	}
+
+	if _, err := BuildFromSorted(4, 0, &entsIter{ents: midNumEnts[:100]}); err == nil {
+		t.Fatalf("BuildFromSorted() accepted fill=0")
+	}
+}
+
+func TestTransient(t *testing.T) {
+	for _, order := range []int{3, 4, 7, 32} {
+		orig := NewBpTree(order)
+		for _, ent := range midNumEnts[:len(midNumEnts)/2] {
+			orig, _ = orig.Put(ent.key, ent.val)
+		}
+
+		tr := orig.Transient()
+		for _, ent := range genRandomizedEntries(midNumEnts) {
+			_, inOrig := orig.Get(ent.key)
+			if added := tr.Put(ent.key, ent.val*2); added == inOrig {
+				t.Fatalf("order=%d: transient Put(%q) returned added=%v", order, ent.key, added)
+			}
+		}
+		for i := 0; i < len(midNumEnts); i += 3 {
+			val, found := tr.Del(midNumEnts[i].key)
+			if !found || val != midNumEnts[i].val*2 {
+				t.Fatalf("order=%d: transient Del(%q) = %v, %v", order, midNumEnts[i].key, val, found)
+			}
+		}
+		bpt := tr.Persistent()
+
+		if !validTree(&bpt.(*tree).Tree) {
+			t.Fatalf("order=%d: Persistent() returned an invalid tree", order)
+		}
+		if bpt.NumberOfEntries() != len(midNumEnts)-(len(midNumEnts)+2)/3 {
+			t.Fatalf("order=%d: NumberOfEntries()=%d", order, bpt.NumberOfEntries())
+		}
+		for i, ent := range midNumEnts {
+			val, found := bpt.Get(ent.key)
+			if i%3 == 0 {
+				if found {
+					t.Fatalf("order=%d: deleted key %q was found", order, ent.key)
+				}
+			} else if !found || val != ent.val*2 {
+				t.Fatalf("order=%d: Get(%q) = %v, %v; expected %v", order, ent.key, val, found, ent.val*2)
+			}
+		}
+
+		//the original is untouched
+		if !validTree(&orig.(*tree).Tree) || orig.NumberOfEntries() != len(midNumEnts)/2 {
+			t.Fatalf("order=%d: the original tree was modified", order)
+		}
+		var n int
+		for it := orig.Iter(); it.Next(); n++ {
+			if !it.Key().Equals(midNumEnts[n].key) || it.Val() != midNumEnts[n].val {
+				t.Fatalf("order=%d: the original tree was modified at entry %d", order, n)
+			}
+		}
+
+		//the frozen tree is copied on write like any other
+		bpt2, _ := bpt.Put(midNumEnts[1].key, -1)
+		if v, _ := bpt.Get(midNumEnts[1].key); v != midNumEnts[1].val*2 {
+			t.Fatalf("order=%d: Put() on a Persistent() tree modified it", order)
+		}
+		if v, _ := bpt2.Get(midNumEnts[1].key); v != -1 {
+			t.Fatalf("order=%d: Put() on a Persistent() tree was lost", order)
+		}
+	}
+}
+
+func TestTransientCopiesEachNodeOnce(t *testing.T) {
+	bpt := NewBpTree(32)
+	for _, ent := range midNumEnts {
+		bpt, _ = bpt.Put(ent.key, ent.val)
+	}
+	tr := bpt.Transient()
+	tr.Put(midNumEnts[100].key, -1)
+	leaf0, _ := tr.(*transientS).t.findLeaf(midNumEnts[100].key)
+	tr.Put(midNumEnts[101].key, -1)
+	leaf1, _ := tr.(*transientS).t.findLeaf(midNumEnts[101].key)
+	if leaf0 != leaf1 {
+		t.Fatalf("second Put() into the same leaf copied it again")
+	}
+	tr.Persistent()
+
+	defer func() {
+		if recover() == nil {
+			t.Fatalf("using a transient after Persistent() did not panic")
+		}
+	}()
+	tr.Put(midNumEnts[0].key, 0)
+}
+
+func TestApplyBatch(t *testing.T) {
+	bpt := NewBpTree(4)
+	for _, ent := range midNumEnts[:1000] {
+		bpt, _ = bpt.Put(ent.key, ent.val)
+	}
+
+	ops := []BatchOp{
+		{Key: midNumEnts[10].key, Val: -10},     //replace
+		{Key: midNumEnts[2000].key, Val: -2000}, //add
+		{Del: true, Key: midNumEnts[20].key},    //remove
+		{Del: true, Key: midNumEnts[3000].key},  //not found
+		{Key: midNumEnts[2000].key, Val: -2001}, //replace the earlier add
+		{Del: true, Key: midNumEnts[10].key},    //remove the earlier replace
+		{Key: midNumEnts[20].key, Val: -20},     //add back the earlier remove
+		{Del: true, Key: midNumEnts[20].key},    //and remove it again
+		{Del: true, Key: midNumEnts[20].key},    //not found any more
+		{Key: midNumEnts[500].key, Val: -500},   //replace
+	}
+	expected := []BatchResult{
+		{BatchReplaced, midNumEnts[10].val},
+		{BatchAdded, nil},
+		{BatchRemoved, midNumEnts[20].val},
+		{BatchNotFound, nil},
+		{BatchReplaced, -2000},
+		{BatchRemoved, -10},
+		{BatchAdded, nil},
+		{BatchRemoved, -20},
+		{BatchNotFound, nil},
+		{BatchReplaced, midNumEnts[500].val},
+	}
+
+	nbpt, results := bpt.Apply(ops)
+	for i := range expected {
+		if results[i] != expected[i] {
+			t.Fatalf("results[%d] = %v; expected %v", i, results[i], expected[i])
+		}
+	}
+	if !validTree(&nbpt.(*tree).Tree) {
+		t.Fatalf("Apply() returned an invalid tree")
+	}
+	if nbpt.NumberOfEntries() != 999 {
+		t.Fatalf("NumberOfEntries()=%d; expected 999", nbpt.NumberOfEntries())
+	}
+	for _, check := range []struct {
+		i     int
+		val   interface{}
+		found bool
+	}{{10, nil, false}, {20, nil, false}, {2000, -2001, true}, {500, -500, true}, {11, midNumEnts[11].val, true}} {
+		val, found := nbpt.Get(midNumEnts[check.i].key)
+		if val != check.val || found != check.found {
+			t.Fatalf("Get(%q) = %v, %v; expected %v, %v", midNumEnts[check.i].key, val, found, check.val, check.found)
+		}
+	}
+
+	//the receiver is untouched
+	if bpt.NumberOfEntries() != 1000 || !validTree(&bpt.(*tree).Tree) {
+		t.Fatalf("Apply() modified the receiver")
+	}
+	if v, _ := bpt.Get(midNumEnts[10].key); v != midNumEnts[10].val {
+		t.Fatalf("Apply() modified the receiver")
+	}
+
+	//a batch that changes nothing returns the receiver
+	if nbpt2, _ := nbpt.Apply([]BatchOp{{Del: true, Key: midNumEnts[5000].key}}); nbpt2 != nbpt {
+		t.Fatalf("a batch that changes nothing should return the receiver")
+	}
+}
+
+func TestApplyLargeRandomBatch(t *testing.T) {
+	bpt := NewBpTree(5)
+	ops := make([]BatchOp, 0, len(midNumEnts))
+	for _, ent := range genRandomizedEntries(midNumEnts) {
+		ops = append(ops, BatchOp{Key: ent.key, Val: ent.val})
+	}
+	for _, ent := range genRandomizedEntries(midNumEnts)[:len(midNumEnts)/2] {
+		ops = append(ops, BatchOp{Del: true, Key: ent.key})
+	}
+	nbpt, _ := bpt.Apply(ops)
+	if !validTree(&nbpt.(*tree).Tree) {
+		t.Fatalf("Apply() returned an invalid tree")
+	}
+	if nbpt.NumberOfEntries() != len(midNumEnts)-len(midNumEnts)/2 {
+		t.Fatalf("NumberOfEntries()=%d", nbpt.NumberOfEntries())
+	}
+	if !bpt.IsEmpty() {
+		t.Fatalf("Apply() modified the receiver")
+	}
+}
+
+func TestStructuralEquals(t *testing.T) {
+	t0 := NewBpTree(3)
+	t1 := NewBpTree(7)
+	for _, ent := range midNumEnts {
+		t0, _ = t0.Put(ent.key, ent.val)
+	}
+	for _, ent := range genRandomizedEntries(midNumEnts) {
+		t1, _ = t1.Put(ent.key, ent.val)
+	}
+	if !t0.Equals(t1) || !t1.Equals(t0) {
+		t.Fatalf("trees with the same entries built in different orders should be Equals()")
+	}
+
+	t2, _ := t0.Put(midNumEnts[1234].key, -1)
+	if t0.Equals(t2) || t2.Equals(t0) {
+		t.Fatalf("trees with a different value should not be Equals()")
+	}
+	t3, _, _ := t0.Del(midNumEnts[4321].key)
+	if t0.Equals(t3) || t3.Equals(t0) {
+		t.Fatalf("trees with a different number of entries should not be Equals()")
+	}
+	t4, _ := t3.Put(StringKey("zzzzzz"), midNumEnts[4321].val)
+	if t0.Equals(t4) || t4.Equals(t0) {
+		t.Fatalf("trees with a different key should not be Equals()")
+	}
+	t5, _ := t3.Put(midNumEnts[4321].key, midNumEnts[4321].val)
+	if !t0.Equals(t5) || !t5.Equals(t0) {
+		t.Fatalf("a version that was changed back should be Equals() to the original")
+	}
+	if !NewBpTree(3).Equals(NewBpTree(5)) {
+		t.Fatalf("empty trees should be Equals()")
+	}
+
+	//versions that share most of their nodes skip the shared subtrees
+	var ncmp int
+	counting := func(a, b interface{}) bool {
+		ncmp++
+		return a == b
+	}
+	t6, _ := t0.Put(midNumEnts[1234].key, midNumEnts[1234].val)
+	if !t0.EqualsFunc(t6, counting) {
+		t.Fatalf("a version with an identical Put() should be Equals() to the original")
+	}
+	if ncmp > 100 {
+		t.Fatalf("EqualsFunc() compared %d values; shared subtrees were not skipped", ncmp)
+	}
+}
+
+func TestEqualsFuncWithUncomparableValues(t *testing.T) {
+	t0 := NewBpTree(4)
+	t1 := NewBpTree(4)
+	for i, ent := range midNumEnts[:500] {
+		t0, _ = t0.Put(ent.key, []int{ent.val})
+		rent := midNumEnts[499-i]
+		t1, _ = t1.Put(rent.key, []int{rent.val})
+	}
+	if !t0.Equals(t1) {
+		t.Fatalf("trees with equal slice values should be Equals()")
+	}
+	byLen := func(a, b interface{}) bool { return len(a.([]int)) == len(b.([]int)) }
+	t2, _ := t1.Put(midNumEnts[7].key, []int{-1})
+	if t0.Equals(t2) {
+		t.Fatalf("trees with different slice values should not be Equals()")
+	}
+	if !t0.EqualsFunc(t2, byLen) {
+		t.Fatalf("EqualsFunc() did not use the supplied value comparison")
+	}
+}
+
+func TestDiff(t *testing.T) {
+	old := NewBpTree(4)
+	for i := 0; i < len(midNumEnts); i += 2 {
+		old, _ = old.Put(midNumEnts[i].key, midNumEnts[i].val)
+	}
+
+	//expected differences indexed by position in midNumEnts
+	expected := make(map[int]DiffEntry)
+	new := old
+	for n := 0; n < 300; n++ {
+		i := rand.Intn(len(midNumEnts))
+		ent := midNumEnts[i]
+		oldVal, inOld := old.Get(ent.key)
+		if rand.Intn(2) == 0 {
+			new, _, _ = new.Del(ent.key)
+			if inOld {
+				expected[i] = DiffEntry{DiffRemoved, ent.key, oldVal, nil}
+			} else {
+				delete(expected, i)
+			}
+		} else {
+			new, _ = new.Put(ent.key, -n)
+			if inOld {
+				expected[i] = DiffEntry{DiffChanged, ent.key, oldVal, -n}
+			} else {
+				expected[i] = DiffEntry{DiffAdded, ent.key, nil, -n}
+			}
+		}
+	}
+
+	var ncmp int
+	counting := func(a, b interface{}) bool {
+		ncmp++
+		return a == b
+	}
+	diffs := DiffFunc(old, new, counting)
+	var i, j int
+	for ; i < len(midNumEnts); i++ {
+		exp, ok := expected[i]
+		if !ok {
+			continue
+		}
+		if j >= len(diffs) {
+			t.Fatalf("Diff() is missing %v", exp)
+		}
+		if diffs[j].Kind != exp.Kind || !diffs[j].Key.Equals(exp.Key) ||
+			diffs[j].OldVal != exp.OldVal || diffs[j].NewVal != exp.NewVal {
+			t.Fatalf("diffs[%d] = %v; expected %v", j, diffs[j], exp)
+		}
+		j++
+	}
+	if j != len(diffs) {
+		t.Fatalf("Diff() returned %d differences; expected %d", len(diffs), j)
+	}
+	if ncmp > len(midNumEnts)/4 {
+		t.Fatalf("DiffFunc() compared %d values; shared subtrees were not skipped", ncmp)
+	}
+
+	if len(Diff(new, new)) != 0 {
+		t.Fatalf("Diff() of a tree with itself should be empty")
+	}
+	rdiffs := Diff(new, old)
+	if len(rdiffs) != len(diffs) {
+		t.Fatalf("Diff(new, old) returned %d differences; expected %d", len(rdiffs), len(diffs))
+	}
+	for k := range rdiffs {
+		if (rdiffs[k].Kind == DiffAdded) != (diffs[k].Kind == DiffRemoved) {
+			t.Fatalf("Diff(new, old)[%d] = %v is not the reverse of %v", k, rdiffs[k], diffs[k])
+		}
+	}
+}
+
+func TestMerge(t *testing.T) {
+	base := NewBpTree(5)
+	for _, ent := range midNumEnts[:2000] {
+		base, _ = base.Put(ent.key, ent.val)
+	}
+	k := func(i int) BptKey { return midNumEnts[i].key }
+
+	left, right := base, base
+	left, _ = left.Put(k(10), -10)       //left only change
+	right, _, _ = right.Del(k(20))       //right only delete
+	right, _ = right.Put(k(3000), -3000) //right only add
+	left, _ = left.Put(k(30), -30)       //same change on both sides
+	right, _ = right.Put(k(30), -30)
+	left, _, _ = left.Del(k(40)) //same delete on both sides
+	right, _, _ = right.Del(k(40))
+	left, _ = left.Put(k(50), -50) //conflicting changes
+	right, _ = right.Put(k(50), -5050)
+	left, _, _ = left.Del(k(60)) //delete vs change
+	right, _ = right.Put(k(60), -60)
+	left, _ = left.Put(k(4000), -4000) //conflicting adds
+	right, _ = right.Put(k(4000), -4444)
+	for i := 100; i < 200; i++ { //lots of left only changes
+		left, _ = left.Put(k(i), -i)
+	}
+
+	var conflicts []MergeConflict
+	merged := Merge(base, left, right, func(c MergeConflict) (interface{}, bool) {
+		conflicts = append(conflicts, c)
+		if !c.InRight {
+			return nil, false
+		}
+		return c.Right, true
+	})
+
+	if len(conflicts) != 3 {
+		t.Fatalf("resolver was called %d times; expected 3; conflicts=%v", len(conflicts), conflicts)
+	}
+	c := conflicts[0]
+	if !c.Key.Equals(k(50)) || c.Base != midNumEnts[50].val || c.Left != -50 || c.Right != -5050 || !c.InBase || !c.InLeft || !c.InRight {
+		t.Fatalf("conflicts[0] = %+v", c)
+	}
+	c = conflicts[1]
+	if !c.Key.Equals(k(60)) || c.InLeft || !c.InRight || c.Right != -60 {
+		t.Fatalf("conflicts[1] = %+v", c)
+	}
+	c = conflicts[2]
+	if !c.Key.Equals(k(4000)) || c.InBase || c.Left != -4000 || c.Right != -4444 {
+		t.Fatalf("conflicts[2] = %+v", c)
+	}
+
+	expected := base
+	expected, _ = expected.Put(k(10), -10)
+	expected, _, _ = expected.Del(k(20))
+	expected, _ = expected.Put(k(3000), -3000)
+	expected, _ = expected.Put(k(30), -30)
+	expected, _, _ = expected.Del(k(40))
+	expected, _ = expected.Put(k(50), -5050)
+	expected, _ = expected.Put(k(60), -60)
+	expected, _ = expected.Put(k(4000), -4444)
+	for i := 100; i < 200; i++ {
+		expected, _ = expected.Put(k(i), -i)
+	}
+	if !validTree(&merged.(*tree).Tree) {
+		t.Fatalf("Merge() returned an invalid tree")
+	}
+	if !merged.Equals(expected) {
+		t.Fatalf("Merge() returned the wrong entries; Diff(expected, merged)=%v", Diff(expected, merged))
+	}
+
+	//the inputs are untouched
+	if base.NumberOfEntries() != 2000 || !validTree(&base.(*tree).Tree) {
+		t.Fatalf("Merge() modified base")
+	}
+	if v, _ := left.Get(k(50)); v != -50 {
+		t.Fatalf("Merge() modified left")
+	}
+
+	//merging with an unchanged side returns the other side
+	if Merge(base, left, base, nil) != left {
+		t.Fatalf("Merge(base, left, base) should return left itself")
+	}
+}
+
+func _checkSetOp(t *testing.T, name string, got BpTree, a, b map[int]int, keep func(inA, inB bool) bool, val func(i int) int) {
+	var n int
+	for i := 0; i < len(midNumEnts); i++ {
+		_, inA := a[i]
+		_, inB := b[i]
+		v, found := got.Get(midNumEnts[i].key)
+		if found != keep(inA, inB) {
+			t.Fatalf("%s: Get(%q) found=%t; inA=%t inB=%t", name, midNumEnts[i].key, found, inA, inB)
+		}
+		if !found {
+			continue
+		}
+		n++
+		if v != val(i) {
+			t.Fatalf("%s: Get(%q) => %v; expected %v", name, midNumEnts[i].key, v, val(i))
+		}
+	}
+	if got.NumberOfEntries() != n {
+		t.Fatalf("%s: NumberOfEntries()=%d; expected %d", name, got.NumberOfEntries(), n)
+	}
+	if !_validTree(t, got) {
+		t.Fatalf("%s: invalid tree", name)
+	}
+}
+
+func TestSetOps(t *testing.T) {
+	for _, order := range []int{3, 4, 7, 32} {
+		//a and b are mostly independent, with long runs that only one has
+		a, b := NewBpTree(order), NewBpTree(order)
+		am, bm := make(map[int]int), make(map[int]int)
+		for i := 0; i < 3000; i++ {
+			if (i/100)%3 != 1 && rand.Intn(4) != 0 {
+				a, _ = a.Put(midNumEnts[i].key, i)
+				am[i] = i
+			}
+			if (i/100)%3 != 0 && rand.Intn(4) != 0 {
+				b, _ = b.Put(midNumEnts[i].key, -i)
+				bm[i] = -i
+			}
+		}
+
+		sum := func(k BptKey, x, y interface{}) interface{} {
+			return x.(int) + y.(int)
+		}
+		fromA := func(i int) int { return am[i] }
+		_checkSetOp(t, "Union", Union(a, b, nil), am, bm,
+			func(inA, inB bool) bool { return inA || inB },
+			func(i int) int {
+				if v, ok := am[i]; ok {
+					return v
+				}
+				return bm[i]
+			})
+		_checkSetOp(t, "Union(sum)", Union(a, b, sum), am, bm,
+			func(inA, inB bool) bool { return inA || inB },
+			func(i int) int { return am[i] + bm[i] })
+		_checkSetOp(t, "Intersect", Intersect(a, b, nil), am, bm,
+			func(inA, inB bool) bool { return inA && inB }, fromA)
+		_checkSetOp(t, "Intersect(sum)", Intersect(a, b, sum), am, bm,
+			func(inA, inB bool) bool { return inA && inB },
+			func(i int) int { return am[i] + bm[i] })
+		_checkSetOp(t, "Subtract", Subtract(a, b), am, bm,
+			func(inA, inB bool) bool { return inA && !inB }, fromA)
+
+		//c is a version of a, so they share most of their subtrees
+		c := a
+		cm := make(map[int]int)
+		for i, v := range am {
+			cm[i] = v
+		}
+		for n := 0; n < 20; n++ {
+			i := rand.Intn(3000)
+			if rand.Intn(2) == 0 {
+				c, _, _ = c.Del(midNumEnts[i].key)
+				delete(cm, i)
+			} else {
+				c, _ = c.Put(midNumEnts[i].key, i)
+				cm[i] = i
+			}
+		}
+		_checkSetOp(t, "Union(versions)", Union(a, c, nil), am, cm,
+			func(inA, inB bool) bool { return inA || inB },
+			func(i int) int { return i })
+		_checkSetOp(t, "Intersect(versions)", Intersect(c, a, nil), cm, am,
+			func(inA, inB bool) bool { return inA && inB },
+			func(i int) int { return i })
+		_checkSetOp(t, "Subtract(versions)", Subtract(a, c), am, cm,
+			func(inA, inB bool) bool { return inA && !inB },
+			func(i int) int { return i })
+	}
+
+	a := NewBpTree(4)
+	a, _ = a.Put(StringKey("a"), 1)
+	if Union(a, a, nil) != a || Intersect(a, a, nil) != a || !Subtract(a, a).IsEmpty() {
+		t.Fatal("set operations on a tree with itself should not build a new tree")
+	}
+}
+
+func _checkEnts(t *testing.T, name string, bpt BpTree, ents []entry) {
+	if !validTree(&bpt.(*tree).Tree) {
+		t.Fatalf("%s: invalid tree=\n%v", name, bpt)
+	}
+	if bpt.NumberOfEntries() != len(ents) {
+		t.Fatalf("%s: NumberOfEntries()=%d; expected %d", name, bpt.NumberOfEntries(), len(ents))
+	}
+	var i int
+	for it := bpt.Iter(); it.Next(); i++ {
+		if !it.Key().Equals(ents[i].key) || it.Val() != ents[i].val {
+			t.Fatalf("%s: entry %d = {%q %v}; expected %v", name, i, it.Key(), it.Val(), ents[i])
+		}
+	}
+}
+
+func TestSplitAtAndJoin(t *testing.T) {
+	for _, order := range []int{3, 4, 5, 8, 32} {
+		ents := midNumEnts[:2000]
+		bpt := NewBpTree(order)
+		for _, ent := range genRandomizedEntries(ents) {
+			bpt, _ = bpt.Put(ent.key, ent.val)
+		}
+
+		for _, i := range []int{0, 1, 2, order, 999, 1000, 1998, 1999, 2000, rand.Intn(2000)} {
+			key := BptKey(StringKey("zzzzzzzzzz")) //greater than every key in ents
+			if i < len(ents) {
+				key = ents[i].key
+			}
+			left, right := bpt.SplitAt(key)
+			_checkEnts(t, "SplitAt left", left, ents[:i])
+			_checkEnts(t, "SplitAt right", right, ents[i:])
+			_checkEnts(t, "Join", Join(left, right), ents)
+		}
+		_checkEnts(t, "original", bpt, ents)
+
+		//join trees of very different heights
+		for _, n := range []int{1, order / 2, order, order * order} {
+			small := NewBpTree(order)
+			for _, ent := range ents[:n] {
+				small, _ = small.Put(ent.key, ent.val)
+			}
+			big, _ := BuildFromSorted(order, 1, &entsIter{ents: ents[n:]})
+			_checkEnts(t, "Join(small, big)", Join(small, big), ents)
+
+			small = NewBpTree(order)
+			for _, ent := range ents[len(ents)-n:] {
+				small, _ = small.Put(ent.key, ent.val)
+			}
+			big, _ = BuildFromSorted(order, 0.5, &entsIter{ents: ents[:len(ents)-n]})
+			_checkEnts(t, "Join(big, small)", Join(big, small), ents)
+		}
+	}
+}
+
+func TestDelRange(t *testing.T) {
+	for _, order := range []int{3, 4, 7, 32} {
+		ents := midNumEnts[:3000]
+		bpt, _ := BuildFromSorted(order, 0.75, &entsIter{ents: ents})
+
+		for n := 0; n < 20; n++ {
+			i, j := rand.Intn(len(ents)), rand.Intn(len(ents))
+			if j < i {
+				i, j = j, i
+			}
+			nbpt, removed := bpt.DelRange(ents[i].key, ents[j].key)
+			if removed != j-i {
+				t.Fatalf("DelRange(%q, %q) removed %d; expected %d", ents[i].key, ents[j].key, removed, j-i)
+			}
+			rest := append(append([]entry(nil), ents[:i]...), ents[j:]...)
+			_checkEnts(t, "DelRange", nbpt, rest)
+			if removed == 0 && nbpt != bpt {
+				t.Fatalf("DelRange() of an empty range did not return the original tree")
+			}
+		}
+
+		//truncation below and above a cutoff
+		cut := rand.Intn(len(ents))
+		nbpt, removed := bpt.DelRange(nil, ents[cut].key)
+		if removed != cut {
+			t.Fatalf("DelRange(nil, %q) removed %d; expected %d", ents[cut].key, removed, cut)
+		}
+		_checkEnts(t, "DelRange(nil, hi)", nbpt, ents[cut:])
+		nbpt, removed = bpt.DelRange(ents[cut].key, nil)
+		if removed != len(ents)-cut {
+			t.Fatalf("DelRange(%q, nil) removed %d; expected %d", ents[cut].key, removed, len(ents)-cut)
+		}
+		_checkEnts(t, "DelRange(lo, nil)", nbpt, ents[:cut])
+		nbpt, removed = bpt.DelRange(nil, nil)
+		if removed != len(ents) || !nbpt.IsEmpty() {
+			t.Fatalf("DelRange(nil, nil) removed %d and left %d entries", removed, nbpt.NumberOfEntries())
+		}
+
+		_checkEnts(t, "original", bpt, ents)
+	}
+}
+
+func TestConditionalUpdates(t *testing.T) {
+	bpt := NewBpTree(4)
+	for i := 0; i < 1000; i += 2 {
+		bpt, _ = bpt.Put(midNumEnts[i].key, midNumEnts[i].val)
+	}
+	present, absent := midNumEnts[500], midNumEnts[501]
+
+	if nbpt, added := bpt.PutIfAbsent(present.key, -1); added || nbpt != bpt {
+		t.Fatalf("PutIfAbsent(%q) of a present key changed the tree", present.key)
+	}
+	nbpt, added := bpt.PutIfAbsent(absent.key, -1)
+	if val, _ := nbpt.Get(absent.key); !added || val != -1 || nbpt.NumberOfEntries() != 501 {
+		t.Fatalf("PutIfAbsent(%q) of an absent key => %v, %t", absent.key, val, added)
+	}
+
+	if nbpt, _, replaced := bpt.Replace(absent.key, -1); replaced || nbpt != bpt {
+		t.Fatalf("Replace(%q) of an absent key changed the tree", absent.key)
+	}
+	nbpt, old, replaced := bpt.Replace(present.key, -1)
+	if val, _ := nbpt.Get(present.key); !replaced || old != present.val || val != -1 {
+		t.Fatalf("Replace(%q) => old=%v, %t; new val=%v", present.key, old, replaced, val)
+	}
+	if nbpt, old, replaced := bpt.Replace(present.key, present.val); !replaced || old != present.val || !SameVersion(nbpt, bpt) {
+		t.Fatalf("Replace(%q) with the value already stored changed the tree", present.key)
+	}
+
+	if nbpt, swapped := bpt.CompareAndSwap(present.key, -2, -1); swapped || nbpt != bpt {
+		t.Fatalf("CompareAndSwap(%q) with the wrong old value changed the tree", present.key)
+	}
+	if nbpt, swapped := bpt.CompareAndSwap(absent.key, nil, -1); swapped || nbpt != bpt {
+		t.Fatalf("CompareAndSwap(%q) of an absent key changed the tree", absent.key)
+	}
+	nbpt, swapped := bpt.CompareAndSwap(present.key, present.val, -1)
+	if val, _ := nbpt.Get(present.key); !swapped || val != -1 {
+		t.Fatalf("CompareAndSwap(%q) => %t; new val=%v", present.key, swapped, val)
+	}
+	if nbpt, swapped := bpt.CompareAndSwap(present.key, present.val, present.val); !swapped || !SameVersion(nbpt, bpt) {
+		t.Fatalf("CompareAndSwap(%q) of a value for an equal one changed the tree", present.key)
+	}
+
+	incr := func(old interface{}, found bool) (interface{}, bool) {
+		if !found {
+			return 1, true
+		}
+		return old.(int) + 1, true
+	}
+	nbpt = bpt.Update(present.key, incr).Update(absent.key, incr)
+	if val, _ := nbpt.Get(present.key); val != present.val+1 {
+		t.Fatalf("Update(%q) stored %v; expected %v", present.key, val, present.val+1)
+	}
+	if val, _ := nbpt.Get(absent.key); val != 1 {
+		t.Fatalf("Update(%q) stored %v; expected 1", absent.key, val)
+	}
+	keepOld := func(old interface{}, found bool) (interface{}, bool) {
+		return old, true
+	}
+	if !SameVersion(bpt.Update(present.key, keepOld), bpt) {
+		t.Fatalf("Update(%q) with the value already stored changed the tree", present.key)
+	}
+	drop := func(old interface{}, found bool) (interface{}, bool) {
+		return nil, false
+	}
+	if bpt.Update(absent.key, drop) != bpt {
+		t.Fatalf("Update(%q) that deletes an absent key changed the tree", absent.key)
+	}
+	nbpt = bpt.Update(present.key, drop)
+	if _, found := nbpt.Get(present.key); found || nbpt.NumberOfEntries() != 499 || !validTree(&nbpt.(*tree).Tree) {
+		t.Fatalf("Update(%q) that deletes did not delete it", present.key)
+	}
+
+	if val, _ := bpt.Get(present.key); val != present.val || bpt.NumberOfEntries() != 500 {
+		t.Fatalf("conditional updates modified the original tree")
+	}
+}
+
+func TestWithValEquals(t *testing.T) {
+	plain := NewBpTree(4)
+	bpt := NewBpTree(4, WithValEquals(nil))
+	for i := 0; i < 100; i++ {
+		plain, _ = plain.Put(midNumEnts[i].key, []int{i})
+		bpt, _ = bpt.Put(midNumEnts[i].key, []int{i})
+	}
+	key := midNumEnts[50].key
+
+	if nbpt, _ := plain.Put(key, []int{50}); SameVersion(nbpt, plain) {
+		t.Fatalf("Put() of an equal value without WithValEquals() did not make a new version")
+	}
+	nbpt, added := bpt.Put(key, []int{50})
+	if added || !SameVersion(nbpt, bpt) {
+		t.Fatalf("Put() of an equal value returned a new version")
+	}
+	nbpt, _ = bpt.Put(key, []int{-50})
+	if SameVersion(nbpt, bpt) {
+		t.Fatalf("Put() of a different value returned the same version")
+	}
+	if val, _ := nbpt.Get(key); val.([]int)[0] != -50 {
+		t.Fatalf("Get(%q) => %v; expected [-50]", key, val)
+	}
+
+	//the option is inherited by every derived version
+	nbpt, _, _ = nbpt.Del(midNumEnts[0].key)
+	if nbpt2, _ := nbpt.Put(key, []int{-50}); !SameVersion(nbpt2, nbpt) {
+		t.Fatalf("derived version lost WithValEquals()")
+	}
+	left, right := nbpt.SplitAt(key)
+	if nright, _ := right.Put(key, []int{-50}); !SameVersion(nright, right) {
+		t.Fatalf("SplitAt() lost WithValEquals()")
+	}
+	if Join(left, right).(*tree).valEq == nil {
+		t.Fatalf("Join() lost WithValEquals()")
+	}
+
+	if nbpt, _, _ := bpt.Replace(key, []int{50}); !SameVersion(nbpt, bpt) {
+		t.Fatalf("Replace() of an equal value returned a new version")
+	}
+	if nbpt, _ := bpt.CompareAndSwap(key, []int{50}, []int{50}); !SameVersion(nbpt, bpt) {
+		t.Fatalf("CompareAndSwap() of an equal value returned a new version")
+	}
+	same := func(old interface{}, found bool) (interface{}, bool) {
+		return []int{50}, true
+	}
+	if !SameVersion(bpt.Update(key, same), bpt) {
+		t.Fatalf("Update() to an equal value returned a new version")
+	}
+	nbpt, results := bpt.Apply([]BatchOp{{Key: key, Val: []int{50}}})
+	if !SameVersion(nbpt, bpt) || results[0].Outcome != BatchReplaced {
+		t.Fatalf("Apply() of an equal value => %v, %v", SameVersion(nbpt, bpt), results)
+	}
+}
+
+func TestWithCompare(t *testing.T) {
+	var ncmp int
+	reverse := func(a, b BptKey) int {
+		ncmp++
+		return defaultCompare(b, a)
+	}
+	ents := midNumEnts[:1000]
+	bpt := NewBpTree(5, WithCompare(reverse))
+	for _, ent := range genRandomizedEntries(ents) {
+		bpt, _ = bpt.Put(ent.key, ent.val)
+	}
+	if ncmp == 0 {
+		t.Fatalf("WithCompare() function was never called")
+	}
+
+	i := len(ents) - 1
+	for it := bpt.Iter(); it.Next(); i-- {
+		if !it.Key().Equals(ents[i].key) {
+			t.Fatalf("Iter() => %q; expected %q", it.Key(), ents[i].key)
+		}
+	}
+	if i != -1 {
+		t.Fatalf("Iter() stopped at %d", i)
+	}
+
+	//in reverse order ents[600] comes before ents[400]
+	var n int
+	for it := bpt.Range(ents[600].key, true, ents[400].key, false); it.Next(); n++ {
+		if !it.Key().Equals(ents[600-n].key) {
+			t.Fatalf("Range() => %q; expected %q", it.Key(), ents[600-n].key)
+		}
+	}
+	if n != 200 {
+		t.Fatalf("Range() returned %d entries; expected 200", n)
+	}
+	if rank := bpt.Rank(ents[600].key); rank != len(ents)-1-600 {
+		t.Fatalf("Rank(%q) => %d; expected %d", ents[600].key, rank, len(ents)-1-600)
+	}
+
+	left, right := bpt.SplitAt(ents[500].key)
+	if left.NumberOfEntries() != 499 || !validTree(&Join(left, right).(*tree).Tree) {
+		t.Fatalf("SplitAt() with a reversed compare => %d entries on the left", left.NumberOfEntries())
+	}
+
+	for _, ent := range genRandomizedEntries(ents) {
+		var found bool
+		bpt, _, found = bpt.Del(ent.key)
+		if !found {
+			t.Fatalf("Del(%q) did not find the key", ent.key)
+		}
+	}
+	if !bpt.IsEmpty() {
+		t.Fatalf("tree is not empty after deleting every key")
+	}
+}
+
+//_userKey is a key type from outside the package. It has no Compare()
+//method, and sorts before or after every key of another type.
+type _userKey struct {
+	first bool
+}
+
+func (k _userKey) Equals(K1 BptKey) bool {
+	return k == K1
+}
+
+func (k _userKey) LessThan(K1 BptKey) bool {
+	if k1, ok := K1.(_userKey); ok {
+		return k.first && !k1.first
+	}
+	return k.first
+}
+
+func (k _userKey) String() string {
+	return fmt.Sprintf("_userKey{%t}", k.first)
+}
+
+func TestKeyTypes(t *testing.T) {
+	t0 := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	//each row is in ascending order
+	rows := [][]BptKey{
+		{IntKey(-5), IntKey(0), IntKey(3), IntKey(1 << 40)},
+		{Uint64Key(0), Uint64Key(7), Uint64Key(1 << 63)},
+		{LexStringKey(""), LexStringKey("a"), LexStringKey("aa"), LexStringKey("ab"), LexStringKey("b")},
+		{BytesKey{}, BytesKey{0}, BytesKey{0, 1}, BytesKey{1}, BytesKey{0xff}},
+		{TimeKey(t0.Add(-time.Hour)), TimeKey(t0), TimeKey(t0.Add(time.Nanosecond))},
+		{StringKey("b"), StringKey("aa"), StringKey("ab")},
+	}
+
+	var all []BptKey
+	for _, row := range rows {
+		bpt := NewBpTree(3)
+		for _, i := range rand.Perm(len(row)) {
+			bpt, _ = bpt.Put(row[i], i)
+		}
+		var i int
+		for it := bpt.Iter(); it.Next(); i++ {
+			if !it.Key().Equals(row[i]) {
+				t.Fatalf("Iter() => %q; expected %q", it.Key(), row[i])
+			}
+		}
+		all = append(all, row...)
+	}
+
+	//every pair of keys, including keys of different types, must be
+	//ordered consistently
+	for _, a := range all {
+		for _, b := range all {
+			ab, ba := defaultCompare(a, b), defaultCompare(b, a)
+			if ab != -ba {
+				t.Fatalf("inconsistent ordering of %T(%q) and %T(%q): %d, %d", a, a, b, b, ab, ba)
+			}
+			if (ab == 0) != a.Equals(b) || (ab < 0) != a.LessThan(b) {
+				t.Fatalf("Equals/LessThan of %T(%q) and %T(%q) disagree with Compare", a, a, b, b)
+			}
+		}
+	}
+
+	//a key type from outside the package orders itself against the
+	//built-in ones, whichever side of the comparison it is on
+	users := []BptKey{_userKey{true}, _userKey{false}}
+	for _, u := range users {
+		for _, b := range all {
+			ub, bu := defaultCompare(u, b), defaultCompare(b, u)
+			if ub != -bu || (ub < 0) != u.(_userKey).first {
+				t.Fatalf("inconsistent ordering of %v and %T(%q): %d, %d", u, b, b, ub, bu)
+			}
+			if (bu == 0) != b.Equals(u) || (bu < 0) != b.LessThan(u) {
+				t.Fatalf("Equals/LessThan of %T(%q) and %v disagree with Compare", b, b, u)
+			}
+		}
+	}
+	bpt := NewBpTree(3)
+	for _, i := range rand.Perm(len(all)) {
+		bpt, _ = bpt.Put(all[i], i)
+	}
+	for _, u := range users {
+		bpt, _ = bpt.Put(u, -1)
+	}
+	if !validTree(&bpt.(*tree).Tree) {
+		t.Fatalf("tree mixing user and built-in key types is invalid")
+	}
+	if k, _, _ := bpt.Min(); k != users[0] {
+		t.Fatalf("Min() = %v; expected %v", k, users[0])
+	}
+	if k, _, _ := bpt.Max(); k != users[1] {
+		t.Fatalf("Max() = %v; expected %v", k, users[1])
+	}
+
+	if !IntKey(7).Equals(Uint64Key(7)) || !IntKey(-1).LessThan(Uint64Key(0)) {
+		t.Fatalf("IntKey and Uint64Key do not compare numerically")
+	}
+	if !TimeKey(t0).Equals(TimeKey(t0.In(time.FixedZone("X", 3600)))) {
+		t.Fatalf("TimeKeys for the same instant are not equal")
+	}
+}
+
+func TestTupleKey(t *testing.T) {
+	t0 := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	key := func(tenant, ts, id int) TupleKey {
+		return TupleKey{IntKey(tenant), Desc{TimeKey(t0.Add(time.Duration(ts) * time.Second))}, IntKey(id)}
+	}
+
+	bpt := NewBpTree(4)
+	for tenant := 5; tenant < 10; tenant++ {
+		for ts := 0; ts < 20; ts++ {
+			for id := 0; id < 3; id++ {
+				bpt, _ = bpt.Put(key(tenant, ts, id), tenant)
+			}
+		}
+	}
+
+	//all keys of tenant 7, newest first, ids ascending
+	prefix := TupleKey{IntKey(7)}
+	var n int
+	for it := bpt.Range(prefix, true, prefix.PrefixEnd(), false); it.Next(); n++ {
+		exp := key(7, 19-n/3, n%3)
+		if !it.Key().Equals(exp) {
+			t.Fatalf("Range(%v) entry %d = %v; expected %v", prefix, n, it.Key(), exp)
+		}
+	}
+	if n != 60 {
+		t.Fatalf("Range(%v) returned %d entries; expected 60", prefix, n)
+	}
+
+	//a two component prefix
+	prefix = TupleKey{IntKey(9), Desc{TimeKey(t0.Add(4 * time.Second))}}
+	n = 0
+	for it := bpt.Range(prefix, true, prefix.PrefixEnd(), false); it.Next(); n++ {
+		if !it.Key().Equals(key(9, 4, n)) {
+			t.Fatalf("Range(%v) entry %d = %v", prefix, n, it.Key())
+		}
+	}
+	if n != 3 {
+		t.Fatalf("Range(%v) returned %d entries; expected 3", prefix, n)
+	}
+
+	if !key(1, 2, 3).Equals(key(1, 2, 3)) || key(1, 2, 3).Equals(key(1, 2, 4)) {
+		t.Fatalf("TupleKey.Equals is wrong")
+	}
+	if !(TupleKey{IntKey(1)}).LessThan(key(1, 0, 0)) || !key(1, 0, 0).LessThan(TupleKey{IntKey(1)}.PrefixEnd()) {
+		t.Fatalf("a prefix does not bracket the keys that start with it")
+	}
+}
+
+func TestPrefixScan(t *testing.T) {
+	words := []string{"a", "ab", "abc", "abcd", "abce", "abd", "abz", "ac", "b", "ba", "bab"}
+	bpt := NewBpTree(3)
+	for i, w := range words {
+		bpt, _ = bpt.Put(LexStringKey(w), i)
+	}
+
+	tests := []struct {
+		prefix string
+		limit  int
+		expect []string
+	}{
+		{"ab", 0, []string{"ab", "abc", "abcd", "abce", "abd", "abz"}},
+		{"ab", 3, []string{"ab", "abc", "abcd"}},
+		{"abc", 20, []string{"abc", "abcd", "abce"}},
+		{"b", 0, []string{"b", "ba", "bab"}},
+		{"", 2, []string{"a", "ab"}},
+		{"abb", 0, nil},
+		{"c", 0, nil},
+	}
+	for _, test := range tests {
+		it, err := bpt.PrefixScan(LexStringKey(test.prefix), test.limit)
+		if err != nil {
+			t.Fatalf("PrefixScan(%q) returned err=%v", test.prefix, err)
+		}
+		var got []string
+		for it.Next() {
+			got = append(got, it.Key().String())
+		}
+		if strings.Join(got, " ") != strings.Join(test.expect, " ") {
+			t.Fatalf("PrefixScan(%q, %d) => %v; expected %v", test.prefix, test.limit, got, test.expect)
+		}
+	}
+
+	tbpt := NewBpTree(3)
+	for i := 0; i < 50; i++ {
+		tbpt, _ = tbpt.Put(TupleKey{IntKey(i % 5), BytesKey{byte(i)}}, i)
+	}
+	it, err := tbpt.PrefixScan(TupleKey{IntKey(2)}, 0)
+	if err != nil {
+		t.Fatalf("PrefixScan(TupleKey) returned err=%v", err)
+	}
+	var n int
+	for ; it.Next(); n++ {
+		if it.Val().(int)%5 != 2 {
+			t.Fatalf("PrefixScan(TupleKey) => %v", it.Key())
+		}
+	}
+	if n != 10 {
+		t.Fatalf("PrefixScan(TupleKey) returned %d entries; expected 10", n)
+	}
+
+	if _, err := NewBpTree(3).PrefixScan(StringKey("ab"), 0); err == nil {
+		t.Fatalf("PrefixScan(StringKey) did not return an error")
+	}
+}
+
+//_linearSearchLeaf is the linear scan nodes used before searchLeaf(), with
+//an Equals() and a LessThan() call per key; it is the baseline for
+//BenchmarkNodeSearch.
+func _linearSearchLeaf(keys []BptKey, key BptKey) (int, bool) {
+	for i, k := range keys {
+		if key.Equals(k) {
+			return i, true
+		}
+		if key.LessThan(k) {
+			return i, false
+		}
+	}
+	return len(keys), false
+}
+
+func TestNodeSearch(t *testing.T) {
+	for _, n := range []int{0, 1, 2, 3, 8, 63, 255} {
+		keys := make([]BptKey, n)
+		for i := range keys {
+			keys[i] = midNumEnts[2*i+1].key
+		}
+		for i := 0; i < 2*n+3; i++ {
+			key := midNumEnts[i].key
+			li, lfound := _linearSearchLeaf(keys, key)
+			bi, bfound := searchLeaf(keys, key, defaultCompare)
+			if li != bi || lfound != bfound {
+				t.Fatalf("n=%d: searchLeaf(%q) => %d, %t; expected %d, %t", n, key, bi, bfound, li, lfound)
+			}
+			exp := li
+			if lfound {
+				exp++
+			}
+			if ni := searchNode(keys, key, defaultCompare); ni != exp {
+				t.Fatalf("n=%d: searchNode(%q) => %d; expected %d", n, key, ni, exp)
+			}
+		}
+	}
+}
+
+//BenchmarkNodeSearch compares searching a full leaf linearly, calling
+//Equals() and LessThan(), to the binary search with one three way
+//comparison per probe, across orders.
+func BenchmarkNodeSearch(b *testing.B) {
+	for _, order := range []int{3, 4, 8, 16, 32, 64, 128, 256} {
+		keys := make([]BptKey, order-1)
+		for i := range keys {
+			keys[i] = midNumEnts[i].key
+		}
+		b.Run(fmt.Sprintf("order=%d/linear", order), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				_linearSearchLeaf(keys, keys[i%len(keys)])
+			}
+		})
+		b.Run(fmt.Sprintf("order=%d/binary", order), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				searchLeaf(keys, keys[i%len(keys)], defaultCompare)
+			}
+		})
+	}
+}
+
+//benchOrders are the orders the Put/Get/Del benchmarks run at.
+var benchOrders = []int{3, 7, 16, 32, 64, 128}
+
+//_benchWorkloads returns the entries of the in-order and random workloads.
+//The random one is shuffled with a fixed seed, so that runs to be compared
+//with benchstat do the same work.
+func _benchWorkloads() []struct {
+	name string
+	ents []entry
+} {
+	r := rand.New(rand.NewSource(1))
+	randEnts := make([]entry, len(midNumEnts))
+	for i, j := range r.Perm(len(midNumEnts)) {
+		randEnts[i] = midNumEnts[j]
+	}
+	return []struct {
+		name string
+		ents []entry
+	}{
+		{"inorder", midNumEnts},
+		{"random", randEnts},
+	}
+}
+
+func _benchTree(order int, ents []entry) BpTree {
+	bpt := NewBpTree(order)
+	for _, ent := range ents {
+		bpt, _ = bpt.Put(ent.key, ent.val)
+	}
+	return bpt
+}
+
+//BenchmarkPut measures one Put() into a growing tree; the tree starts over
+//empty after every len(midNumEnts) Put()s.
+func BenchmarkPut(b *testing.B) {
+	for _, wl := range _benchWorkloads() {
+		for _, order := range benchOrders {
+			b.Run(fmt.Sprintf("%s/order=%d", wl.name, order), func(b *testing.B) {
+				b.ReportAllocs()
+				empty := NewBpTree(order)
+				bpt := empty
+				for i := 0; i < b.N; i++ {
+					j := i % len(wl.ents)
+					if j == 0 {
+						bpt = empty
+					}
+					bpt, _ = bpt.Put(wl.ents[j].key, wl.ents[j].val)
+				}
+			})
+		}
+	}
+}
+
+//BenchmarkGet measures one Get() from a tree of len(midNumEnts) entries.
+func BenchmarkGet(b *testing.B) {
+	for _, wl := range _benchWorkloads() {
+		for _, order := range benchOrders {
+			bpt := _benchTree(order, wl.ents)
+			b.Run(fmt.Sprintf("%s/order=%d", wl.name, order), func(b *testing.B) {
+				b.ReportAllocs()
+				for i := 0; i < b.N; i++ {
+					bpt.Get(wl.ents[i%len(wl.ents)].key)
+				}
+			})
+		}
+	}
+}
+
+//BenchmarkDel measures one Del() from a shrinking tree; the tree starts over
+//full after every len(midNumEnts) Del()s. Starting over is free, since the
+//full tree is never modified.
+func BenchmarkDel(b *testing.B) {
+	for _, wl := range _benchWorkloads() {
+		for _, order := range benchOrders {
+			full := _benchTree(order, midNumEnts)
+			b.Run(fmt.Sprintf("%s/order=%d", wl.name, order), func(b *testing.B) {
+				b.ReportAllocs()
+				bpt := full
+				for i := 0; i < b.N; i++ {
+					j := i % len(wl.ents)
+					if j == 0 {
+						bpt = full
+					}
+					bpt, _, _ = bpt.Del(wl.ents[j].key)
+				}
+			})
+		}
+	}
+}
+
+//BenchmarkPutRetainVersions is BenchmarkPut with every version kept alive,
+//as a user keeping a history of versions would. It reports the heap still
+//in use per retained version, which is the cost of the path copied by each
+//Put().
+func BenchmarkPutRetainVersions(b *testing.B) {
+	random := _benchWorkloads()[1].ents
+	for _, order := range benchOrders {
+		b.Run(fmt.Sprintf("order=%d", order), func(b *testing.B) {
+			b.ReportAllocs()
+			versions := make([]BpTree, 0, b.N)
+
+			var before, after runtime.MemStats
+			runtime.GC()
+			runtime.ReadMemStats(&before)
+
+			bpt := NewBpTree(order)
+			for i := 0; i < b.N; i++ {
+				ent := random[i%len(random)]
+				bpt, _ = bpt.Put(ent.key, i)
+				versions = append(versions, bpt)
+			}
+
+			b.StopTimer()
+			runtime.GC()
+			runtime.ReadMemStats(&after)
+			retained := int64(after.HeapAlloc) - int64(before.HeapAlloc)
+			b.ReportMetric(float64(retained)/float64(b.N), "B/version")
+			runtime.KeepAlive(versions)
+		})
+	}
+}
+
+//BenchmarkGetParallel measures Get() from one tree by GOMAXPROCS readers at
+//once. A persistent tree needs no locking, so this should scale with the
+//number of CPUs.
+func BenchmarkGetParallel(b *testing.B) {
+	random := _benchWorkloads()[1].ents
+	for _, order := range benchOrders {
+		bpt := _benchTree(order, random)
+		b.Run(fmt.Sprintf("order=%d", order), func(b *testing.B) {
+			b.ReportAllocs()
+			b.RunParallel(func(pb *testing.PB) {
+				i := rand.Intn(len(random))
+				for pb.Next() {
+					bpt.Get(random[i%len(random)].key)
+					i++
+				}
+			})
+		})
+	}
+}
+
+//_fuzzModel is the reference a fuzzed tree is checked against: a map of
+//its contents plus the keys in sorted order.
+type _fuzzModel struct {
+	vals map[int]int
+	keys []int //indexes into midNumEnts, sorted
+}
+
+func (m *_fuzzModel) put(k, v int) {
+	if _, ok := m.vals[k]; !ok {
+		i := sort.SearchInts(m.keys, k)
+		m.keys = append(m.keys[:i], append([]int{k}, m.keys[i:]...)...)
+	}
+	m.vals[k] = v
+}
+
+func (m *_fuzzModel) del(k int) {
+	if _, ok := m.vals[k]; ok {
+		i := sort.SearchInts(m.keys, k)
+		m.keys = append(m.keys[:i], m.keys[i+1:]...)
+		delete(m.vals, k)
+	}
+}
+
+func (m *_fuzzModel) copy() *_fuzzModel {
+	c := &_fuzzModel{vals: make(map[int]int, len(m.vals))}
+	for k, v := range m.vals {
+		c.vals[k] = v
+	}
+	c.keys = append([]int(nil), m.keys...)
+	return c
+}
+
+//_checkFuzzModel checks that bpt has exactly the contents of m, is valid,
+//and has a depth within the bounds its order and size allow.
+func _checkFuzzModel(t *testing.T, bpt BpTree, m *_fuzzModel) {
+	t.Helper()
+	if !validTree(&bpt.(*tree).Tree) {
+		t.Fatalf("invalid tree=\n%v", bpt)
+	}
+	n := len(m.keys)
+	if bpt.NumberOfEntries() != n {
+		t.Fatalf("NumberOfEntries()=%d; expected %d", bpt.NumberOfEntries(), n)
+	}
+	var i int
+	for it := bpt.Iter(); it.Next(); i++ {
+		if i >= n {
+			t.Fatalf("Iter() returned more than %d entries", n)
+		}
+		k := m.keys[i]
+		if !it.Key().Equals(midNumEnts[k].key) || it.Val() != m.vals[k] {
+			t.Fatalf("entry %d = {%q %v}; expected {%q %v}", i, it.Key(), it.Val(), midNumEnts[k].key, m.vals[k])
+		}
+	}
+	if i != n {
+		t.Fatalf("Iter() returned %d entries; expected %d", i, n)
+	}
+
+	//a tree of depth d > 0 has at least 2*minKids^(d-1) leaves, each with
+	//at least order/2 entries, and at most order^d leaves of order-1
+	order, depth := bpt.Order(), bpt.Depth()
+	minKids := (order + 1) / 2
+	if depth > 0 {
+		least := 2 * (order / 2)
+		for d := 1; d < depth; d++ {
+			least *= minKids
+		}
+		if n < least {
+			t.Fatalf("Depth()=%d is too deep for %d entries at order=%d", depth, n, order)
+		}
+	}
+	most := order - 1
+	for d := 0; d < depth; d++ {
+		most *= order
+	}
+	if n > most {
+		t.Fatalf("Depth()=%d is too shallow for %d entries at order=%d", depth, n, order)
+	}
+}
+
+//_fuzzOp is one decoded fuzz operation: a Put() of, or a Del() of, the key
+//midNumEnts[k].
+type _fuzzOp struct {
+	put bool
+	k   int
+}
+
+//_decodeFuzzOps decodes data into an order, from 3 to 64, followed by a
+//sequence of (op, key) byte pairs. Even op bytes are Put()s and odd ones
+//Del()s; the key byte indexes midNumEnts.
+func _decodeFuzzOps(data []byte) (int, []_fuzzOp) {
+	if len(data) == 0 {
+		return 3, nil
+	}
+	order := 3 + int(data[0])%62
+	var ops []_fuzzOp
+	for i := 1; i+1 < len(data) && len(ops) < 256; i += 2 {
+		ops = append(ops, _fuzzOp{data[i]%2 == 0, int(data[i+1])})
+	}
+	return order, ops
+}
+
+func _addFuzzSeeds(f *testing.F) {
+	f.Add([]byte{})
+	f.Add([]byte{0, 0, 1, 0, 2, 1, 3})
+	seq := []byte{1}
+	for i := 0; i < 200; i++ {
+		seq = append(seq, 0, byte(i))
+	}
+	for i := 0; i < 200; i += 3 {
+		seq = append(seq, 1, byte(i))
+	}
+	f.Add(seq)
+	r := rand.New(rand.NewSource(1))
+	for _, order := range []byte{0, 1, 5, 29, 61} {
+		seq := []byte{order}
+		for i := 0; i < 400; i++ {
+			seq = append(seq, byte(r.Intn(3)), byte(r.Intn(256)))
+		}
+		f.Add(seq)
+	}
+}
+
+//FuzzPutDel applies a fuzzed sequence of Put()s and Del()s to a BpTree and
+//to a map model, checking them against each other after every step. At the
+//end it checks that every earlier version still has the contents it had
+//when it was made, which is the persistence guarantee.
+func FuzzPutDel(f *testing.F) {
+	_addFuzzSeeds(f)
+	f.Fuzz(func(t *testing.T, data []byte) {
+		order, ops := _decodeFuzzOps(data)
+		bpt := NewBpTree(order)
+		m := &_fuzzModel{vals: make(map[int]int)}
+		versions := []BpTree{bpt}
+		models := []*_fuzzModel{m.copy()}
+
+		for i, op := range ops {
+			key := midNumEnts[op.k].key
+			if op.put {
+				bpt, _ = bpt.Put(key, i)
+				m.put(op.k, i)
+			} else {
+				var found bool
+				bpt, _, found = bpt.Del(key)
+				if _, ok := m.vals[op.k]; found != ok {
+					t.Fatalf("Del(%q) found=%t; expected %t", key, found, ok)
+				}
+				m.del(op.k)
+			}
+			_checkFuzzModel(t, bpt, m)
+			versions = append(versions, bpt)
+			models = append(models, m.copy())
+		}
+
+		for i, v := range versions {
+			_checkFuzzModel(t, v, models[i])
+		}
+	})
+}
+
+//FuzzTransientAndApply builds a tree from the first half of a fuzzed
+//sequence of operations, and then applies the second half to it both with
+//a BpTransient and with Apply(). Both results must match the map model, and
+//the tree they started from must be left unchanged.
+func FuzzTransientAndApply(f *testing.F) {
+	_addFuzzSeeds(f)
+	f.Fuzz(func(t *testing.T, data []byte) {
+		order, ops := _decodeFuzzOps(data)
+		base := NewBpTree(order)
+		m := &_fuzzModel{vals: make(map[int]int)}
+		half := len(ops) / 2
+		for i, op := range ops[:half] {
+			if op.put {
+				base, _ = base.Put(midNumEnts[op.k].key, i)
+				m.put(op.k, i)
+			} else {
+				base, _, _ = base.Del(midNumEnts[op.k].key)
+				m.del(op.k)
+			}
+		}
+		baseModel := m.copy()
+
+		tr := base.Transient()
+		batch := make([]BatchOp, 0, len(ops)-half)
+		for i, op := range ops[half:] {
+			key := midNumEnts[op.k].key
+			if op.put {
+				tr.Put(key, half+i)
+				m.put(op.k, half+i)
+			} else {
+				tr.Del(key)
+				m.del(op.k)
+			}
+			batch = append(batch, BatchOp{Del: !op.put, Key: key, Val: half + i})
+		}
+		applied, _ := base.Apply(batch)
+
+		_checkFuzzModel(t, tr.Persistent(), m)
+		_checkFuzzModel(t, applied, m)
+		_checkFuzzModel(t, base, baseModel)
+	})
+}
+
+//_mustPanicMutating checks that fn, which modifies a node of a published
+//version, panics with the report from assertMutable().
+func _mustPanicMutating(t *testing.T, name string, fn func()) {
+	t.Helper()
+	defer func() {
+		r := recover()
+		if r == nil {
+			t.Fatalf("%s: modifying a frozen node did not panic", name)
+		}
+		if msg := fmt.Sprint(r); !strings.Contains(msg, "of a published version") {
+			t.Fatalf("%s: unexpected panic %q", name, msg)
+		}
+	}()
+	fn()
+}
+
+func TestPersistenceChecker(t *testing.T) {
+	defer func(old bool) { ASSERT = old }(ASSERT)
+	ASSERT = true
+
+	//every kind of update runs clean with the checker on
+	versions := []BpTree{NewBpTree(4)}
+	bpt := versions[0]
+	for _, ent := range genRandomizedEntries(midNumEnts[:2000]) {
+		bpt, _ = bpt.Put(ent.key, ent.val)
+		versions = append(versions, bpt)
+	}
+	for i := 0; i < 2000; i += 3 {
+		bpt, _, _ = bpt.Del(midNumEnts[i].key)
+		versions = append(versions, bpt)
+	}
+	tr := bpt.Transient()
+	for _, ent := range midNumEnts[2000:2500] {
+		tr.Put(ent.key, ent.val)
+	}
+	bpt = tr.Persistent()
+	bpt, _ = bpt.Apply([]BatchOp{{Key: midNumEnts[0].key, Val: 0}, {Del: true, Key: midNumEnts[1].key}})
+	l, r := bpt.SplitAt(midNumEnts[1000].key)
+	bpt = Join(l, r)
+	bpt, _ = bpt.DelRange(midNumEnts[100].key, midNumEnts[200].key)
+	bpt = Union(bpt, versions[1000], nil)
+	versions = append(versions, l, r, bpt)
+	for i, v := range versions {
+		if !validTree(&v.(*tree).Tree) {
+			t.Fatalf("versions[%d] is invalid", i)
+		}
+	}
+
+	//but modifying a node of a published version panics
+	root := bpt.(*tree).root.(*interiorNodeS[BptKey, interface{}])
+	leaf := root.findLeftMostLeaf()
+	_mustPanicMutating(t, "leafNodeS.insert", func() {
+		leaf.insert(midNumEnts[0].key, -1, defaultCompare)
+	})
+	_mustPanicMutating(t, "interiorNodeS.swapNode", func() {
+		root.swapNode(root.vals[0], root.vals[0])
+	})
+	_mustPanicMutating(t, "interiorNodeS.removeAt", func() {
+		root.removeAt(len(root.vals) - 1)
+	})
+	for i, v := range versions {
+		if !validTree(&v.(*tree).Tree) {
+			t.Fatalf("versions[%d] was modified", i)
+		}
+	}
+
+	//with the checker off nothing is frozen
+	ASSERT = false
+	bpt, _ = bpt.Put(midNumEnts[0].key, -1)
+	if isFrozen(bpt.(*tree).root) {
+		t.Fatalf("freeze() froze nodes with ASSERT off")
+	}
+}
+
 //func TestRandomPutWithRandomCursor(t *testing.T) {
 //	bpt := NewBpTree(7)
 //	var added bool
@@ -297,9 +2269,9 @@ func _validTree(test *testing.T, t_ BpTree) bool {
 		return true //else _validRootNode(t.root) would have caught it
 	}
 
-	rootNode := t.root.(*interiorNodeS)
+	rootNode := t.root.(*interiorNodeS[BptKey, interface{}])
 
-	nodes := make([]nodeI, 0, 2)
+	nodes := make([]nodeI[BptKey, interface{}], 0, 2)
 
 	//seed the nodes slice
 	for i := 0; i < len(rootNode.vals); i++ {
@@ -308,13 +2280,13 @@ func _validTree(test *testing.T, t_ BpTree) bool {
 
 	for i := 0; i < len(nodes); i++ {
 		if nodes[i].isLeaf() {
-			n := nodes[i].(*leafNodeS)
+			n := nodes[i].(*leafNodeS[BptKey, interface{}])
 			if !_validLeafNode(test, n, t.order) {
 				test.Logf("!_validLeafNode(test, n, t.order) n=\n%v", n)
 				return false
 			}
 		} else {
-			n := nodes[i].(*interiorNodeS)
+			n := nodes[i].(*interiorNodeS[BptKey, interface{}])
 			if !_validInteriorNode(test, n, t.order) {
 				test.Logf("!_validInteriorNode(test, n, t.order) n=\n%v", n)
 				return false
@@ -325,9 +2297,9 @@ func _validTree(test *testing.T, t_ BpTree) bool {
 	return true
 }
 
-func _validRootNode(t *testing.T, n nodeI, order int) bool {
+func _validRootNode(t *testing.T, n nodeI[BptKey, interface{}], order int) bool {
 	if n.isLeaf() {
-		n := n.(*leafNodeS)
+		n := n.(*leafNodeS[BptKey, interface{}])
 		if !(len(n.keys) >= 0 && len(n.keys) <= order-1) {
 			t.Logf("!(len(n.keys) >= 1 && len(n.keys) <= order-1) n=\n%v", n)
 			return false
@@ -337,7 +2309,7 @@ func _validRootNode(t *testing.T, n nodeI, order int) bool {
 			return false
 		}
 	} else {
-		n := n.(*interiorNodeS)
+		n := n.(*interiorNodeS[BptKey, interface{}])
 		if !(len(n.keys) >= 1 && len(n.keys) <= order-1) {
 			t.Logf("!(len(n.keys),%d >= 1 && len(n.keys),%d <= order-1,%d)", len(n.keys), len(n.keys), order-1)
 			return false
@@ -350,8 +2322,8 @@ func _validRootNode(t *testing.T, n nodeI, order int) bool {
 	return true
 }
 
-func _validInteriorNode(t *testing.T, node_ nodeI, order int) bool {
-	node, ok := node_.(*interiorNodeS)
+func _validInteriorNode(t *testing.T, node_ nodeI[BptKey, interface{}], order int) bool {
+	node, ok := node_.(*interiorNodeS[BptKey, interface{}])
 	if !ok {
 		lgr.Printf("The nodeI passed in is not castable to *interiorNodeS")
 		return false
@@ -373,8 +2345,8 @@ func _validInteriorNode(t *testing.T, node_ nodeI, order int) bool {
 	return true
 }
 
-func _validLeafNode(t *testing.T, node_ nodeI, order int) bool {
-	node, ok := node_.(*leafNodeS)
+func _validLeafNode(t *testing.T, node_ nodeI[BptKey, interface{}], order int) bool {
+	node, ok := node_.(*leafNodeS[BptKey, interface{}])
 	if !ok {
 		lgr.Printf("The nodeI passed in is not castable to *leafNodeS")
 		return false
@@ -419,7 +2391,7 @@ func _validNodeKeys(t *testing.T, keys []BptKey, order int) bool {
 	return true
 }
 
-func _validNodeVals(t *testing.T, vals []nodeI, order int) bool {
+func _validNodeVals(t *testing.T, vals []nodeI[BptKey, interface{}], order int) bool {
 	if !(len(vals) >= _intCeil(order, 2) && len(vals) <= order) {
 		t.Logf("!(len(vals),%d >= _intCeil(order, 2),%d && len(vals),%d <= order,%d)", len(vals), _intCeil(order, 2), len(vals), order)
 		return false
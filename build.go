@@ -0,0 +1,250 @@
+package bptree
+
+import (
+	"fmt"
+	"math"
+)
+
+//BuildFromSorted builds a new B+Tree of the given order from the entries of
+//it, which must produce keys in strictly ascending order. Instead of calling
+//Put() once per entry, which copies a root-to-leaf path and splits leaves
+//over and over, the leaves are packed directly and the interior nodes are
+//built bottom-up one level at a time, so it runs in O(n) time.
+//
+//fill is the fraction, greater than 0 and at most 1, of each node that is
+//packed. A fill of 1 packs nodes full, which makes the tallest fan-out but
+//means the first Put() into any leaf splits it; something like 0.75 leaves
+//room for later Put()s. Nodes are never packed below half full, and the last
+//two nodes of each level are rebalanced so no node is left too small.
+//
+//If it produces a key that is not greater than the key before it, an error
+//is returned and no tree is built.
+func BuildFromSorted(order int, fill float64, it BptIter, opts ...Option) (BpTree, error) {
+	if order < 3 {
+		lgr.Panic("Cannot make a BpTree with lessthan order=3")
+	}
+	if !(fill > 0 && fill <= 1) {
+		return nil, fmt.Errorf("BuildFromSorted: fill=%v must be greater than 0 and at most 1", fill)
+	}
+
+	t := &tree{mkTree[BptKey, interface{}](order, defaultCompare)}
+	for _, opt := range opts {
+		opt(t)
+	}
+	b := mkBuilder(&t.Tree, fill)
+	for it.Next() {
+		if err := b.add(it.Key(), it.Val()); err != nil {
+			return nil, err
+		}
+	}
+
+	b.finish()
+	return t.freeze(), nil
+}
+
+//builderT packs a stream of entries with ascending keys into leaves, and
+//then builds the interior nodes over them bottom-up.
+type builderT[K, V any] struct {
+	t         *Tree[K, V] //the tree being built
+	leafFill  int
+	nodeFill  int
+	leaves    []nodeI[K, V]
+	leastKeys []K //leastKeys[i] is the least key in leaves[i]
+	leaf      *leafNodeS[K, V]
+	prevKey   K
+	hasPrev   bool //false until the first entry is added
+}
+
+//mkBuilder returns a builderT that fills in t, which must be empty.
+func mkBuilder[K, V any](t *Tree[K, V], fill float64) *builderT[K, V] {
+	order := t.order
+	b := new(builderT[K, V])
+	b.t = t
+
+	leafMax := order - 1
+	b.leafFill = int(math.Ceil(fill * float64(leafMax)))
+	if b.leafFill < order/2 {
+		b.leafFill = order / 2
+	}
+	if b.leafFill > leafMax {
+		b.leafFill = leafMax
+	}
+
+	b.nodeFill = int(math.Ceil(fill * float64(order)))
+	if b.nodeFill < (order+1)/2 {
+		b.nodeFill = (order + 1) / 2
+	}
+	if b.nodeFill > order {
+		b.nodeFill = order
+	}
+
+	b.leaf = mkLeaf[K, V](order)
+	return b
+}
+
+//add appends an entry. key must be greater than every key added before it.
+func (b *builderT[K, V]) add(key K, val V) error {
+	if b.hasPrev && b.t.cmp(b.prevKey, key) >= 0 {
+		return fmt.Errorf("BuildFromSorted: key %q is not greater than the key before it %q", fmt.Sprint(key), fmt.Sprint(b.prevKey))
+	}
+	b.prevKey = key
+	b.hasPrev = true
+
+	if len(b.leaf.keys) == b.leafFill {
+		b.flushLeaf()
+	}
+	b.leaf.keys = append(b.leaf.keys, key)
+	b.leaf.vals = append(b.leaf.vals, val)
+	b.t.numEnts++
+	return nil
+}
+
+//addLeaf appends every entry of leaf, which must come from a tree of the
+//same order and hold keys greater than every key added before it. When leaf
+//is at least half full, and the leaf being packed is empty or can stand on
+//its own, leaf itself is reused in the new tree rather than being copied.
+func (b *builderT[K, V]) addLeaf(leaf *leafNodeS[K, V]) error {
+	if len(leaf.keys) == 0 {
+		return nil
+	}
+	if b.hasPrev && b.t.cmp(b.prevKey, leaf.keys[0]) >= 0 {
+		return fmt.Errorf("BuildFromSorted: key %q is not greater than the key before it %q", fmt.Sprint(leaf.keys[0]), fmt.Sprint(b.prevKey))
+	}
+	if leaf.isToSmall() || (len(b.leaf.keys) > 0 && b.leaf.isToSmall()) {
+		for i, k := range leaf.keys {
+			if err := b.add(k, leaf.vals[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if len(b.leaf.keys) > 0 {
+		b.flushLeaf()
+	}
+	b.leaves = append(b.leaves, leaf)
+	b.leastKeys = append(b.leastKeys, leaf.keys[0])
+	b.prevKey = leaf.keys[len(leaf.keys)-1]
+	b.hasPrev = true
+	b.t.numEnts += len(leaf.keys)
+	return nil
+}
+
+func (b *builderT[K, V]) flushLeaf() {
+	b.leaves = append(b.leaves, b.leaf)
+	b.leastKeys = append(b.leastKeys, b.leaf.keys[0])
+	b.leaf = mkLeaf[K, V](b.t.order)
+}
+
+//finish builds the interior nodes and returns the finished tree. The
+//builder must not be used afterwards.
+func (b *builderT[K, V]) finish() *Tree[K, V] {
+	t := b.t
+	order := t.order
+
+	if len(b.leaves) == 0 {
+		//zero or one leaf worth of entries
+		t.root = b.leaf
+		return t
+	}
+
+	leaf := b.leaf
+	if len(leaf.keys) == 0 {
+		//the last leaf added was a reused one; it is at least half full
+		leaf = b.leaves[len(b.leaves)-1].(*leafNodeS[K, V])
+		b.leaves = b.leaves[:len(b.leaves)-1]
+		b.leastKeys = b.leastKeys[:len(b.leastKeys)-1]
+	} else if leaf.isToSmall() {
+		//rebalance the last leaf with the one before it
+		prev := b.leaves[len(b.leaves)-1].(*leafNodeS[K, V])
+		keys := append(append([]K(nil), prev.keys...), leaf.keys...)
+		vals := append(append([]V(nil), prev.vals...), leaf.vals...)
+		b.leaves = b.leaves[:len(b.leaves)-1]
+		b.leastKeys = b.leastKeys[:len(b.leastKeys)-1]
+		if len(keys) <= order-1 {
+			leaf = mkLeaf[K, V](order)
+			leaf.keys = append(leaf.keys, keys...)
+			leaf.vals = append(leaf.vals, vals...)
+		} else {
+			half := len(keys) / 2
+			left := mkLeaf[K, V](order)
+			left.keys = append(left.keys, keys[:half]...)
+			left.vals = append(left.vals, vals[:half]...)
+			b.leaves = append(b.leaves, left)
+			b.leastKeys = append(b.leastKeys, left.keys[0])
+
+			leaf = mkLeaf[K, V](order)
+			leaf.keys = append(leaf.keys, keys[half:]...)
+			leaf.vals = append(leaf.vals, vals[half:]...)
+		}
+	}
+	b.leaves = append(b.leaves, leaf)
+	b.leastKeys = append(b.leastKeys, leaf.keys[0])
+
+	level, leastKeys := b.leaves, b.leastKeys
+	for len(level) > 1 {
+		level, leastKeys = buildLevel(order, b.nodeFill, level, leastKeys)
+		t.depth++
+	}
+	t.root = level[0]
+
+	return t
+}
+
+//buildLevel groups the nodes of one level of the tree under new parent
+//nodes of nodeFill children each, and returns the parents and their least
+//keys. The last two parents are rebalanced if the last one would have fewer
+//than the minimum number of children.
+func buildLevel[K, V any](order, nodeFill int, kids []nodeI[K, V], leastKeys []K) ([]nodeI[K, V], []K) {
+	nParents := intCeil(len(kids), nodeFill)
+	sizes := make([]int, nParents)
+	for i := range sizes {
+		sizes[i] = nodeFill
+	}
+	sizes[nParents-1] = len(kids) - (nParents-1)*nodeFill
+
+	if nParents > 1 && sizes[nParents-1] < (order+1)/2 {
+		total := sizes[nParents-2] + sizes[nParents-1]
+		if total <= order {
+			sizes = sizes[:nParents-1]
+			sizes[nParents-2] = total
+		} else {
+			sizes[nParents-2] = total / 2
+			sizes[nParents-1] = total - total/2
+		}
+	}
+
+	parents := make([]nodeI[K, V], 0, len(sizes))
+	parentKeys := make([]K, 0, len(sizes))
+	var start int
+	for _, size := range sizes {
+		node := mkNode[K, V](order)
+		for i := start; i < start+size; i++ {
+			if i > start {
+				node.keys = append(node.keys, leastKeys[i])
+			}
+			node.vals = append(node.vals, kids[i])
+			node.cnts = append(node.cnts, kids[i].count())
+		}
+		parents = append(parents, node)
+		parentKeys = append(parentKeys, leastKeys[start])
+		start += size
+	}
+
+	return parents, parentKeys
+}
+
+//mustAdd is add for callers that already know their keys are in order.
+func (b *builderT[K, V]) mustAdd(key K, val V) {
+	if err := b.add(key, val); err != nil {
+		lgr.Panic(err)
+	}
+}
+
+//mustAddLeaf is addLeaf for callers that already know their keys are in
+//order.
+func (b *builderT[K, V]) mustAddLeaf(leaf *leafNodeS[K, V]) {
+	if err := b.addLeaf(leaf); err != nil {
+		lgr.Panic(err)
+	}
+}
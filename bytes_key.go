@@ -0,0 +1,36 @@
+package bptree
+
+import (
+	"bytes"
+)
+
+//BytesKey is a BptKey implementation for byte slices, ordered
+//lexicographically like bytes.Compare(); construction is simply
+//BytesKey(myslice). The tree keeps the slice, so it must not be modified
+//after it is used as a key.
+type BytesKey []byte
+
+//Compare returns -1, 0 or 1 as the receiver is less than, equal to or
+//greater than the argument. Keys of other types are ordered by type.
+func (k0 BytesKey) Compare(K1 BptKey) int {
+	k1, ok := K1.(BytesKey)
+	if !ok {
+		return compareRanks(k0, K1)
+	}
+	return bytes.Compare(k0, k1)
+}
+
+//Equals is Compare(K1) == 0.
+func (k0 BytesKey) Equals(K1 BptKey) bool {
+	return k0.Compare(K1) == 0
+}
+
+//LessThan is Compare(K1) < 0.
+func (k0 BytesKey) LessThan(K1 BptKey) bool {
+	return k0.Compare(K1) < 0
+}
+
+//String Trivial.
+func (k BytesKey) String() string {
+	return string(k)
+}
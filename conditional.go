@@ -0,0 +1,87 @@
+package bptree
+
+//The conditional updates below look the key up and make the change in the
+//same descent of the tree. When the condition fails they return the
+//receiver itself, so no nodes are copied and callers can detect the no-op
+//with SameVersion().
+
+//PutIfAbsent(key, val) stores val for key only if key is not already in the
+//tree. It returns the new tree and true if val was added, or the receiver
+//and false if key was present.
+func (ot *tree) PutIfAbsent(key BptKey, val interface{}) (BpTree, bool) {
+	oldLeaf, path := ot.findLeaf(key)
+	if _, found := oldLeaf.get(key, ot.cmp); found {
+		return ot, false
+	}
+
+	t := ot.copy()
+	t.putLeaf(oldLeaf, path, key, val)
+	return t.freeze(), true
+}
+
+//Replace(key, val) stores val for key only if key is already in the tree. It
+//returns the new tree, the value val replaced and true, or the receiver, nil
+//and false if key was not present. If val is equal to the value already
+//stored, compared the same way CompareAndSwap() compares values, nothing
+//needs to change and the receiver is returned along with true.
+func (ot *tree) Replace(key BptKey, val interface{}) (BpTree, interface{}, bool) {
+	oldLeaf, path := ot.findLeaf(key)
+	old, found := oldLeaf.get(key, ot.cmp)
+	if !found {
+		return ot, nil, false
+	}
+	if ot.valEquals(old, val) {
+		return ot, old, true
+	}
+
+	t := ot.copy()
+	t.putLeaf(oldLeaf, path, key, val)
+	return t.freeze(), old, true
+}
+
+//CompareAndSwap(key, old, new) stores new for key only if key is in the
+//tree with a value equal to old. Values are compared with the function given
+//to WithValEquals(), or else the same way BpTree.Equals() compares them. It
+//returns the new tree and true if new was stored, or the receiver and false.
+//If new is equal to old nothing needs to change, and the receiver is
+//returned along with true.
+func (ot *tree) CompareAndSwap(key BptKey, old, new interface{}) (BpTree, bool) {
+	oldLeaf, path := ot.findLeaf(key)
+	cur, found := oldLeaf.get(key, ot.cmp)
+	if !found || !ot.valEquals(cur, old) {
+		return ot, false
+	}
+	if ot.valEquals(cur, new) {
+		return ot, true
+	}
+
+	t := ot.copy()
+	t.putLeaf(oldLeaf, path, key, new)
+	return t.freeze(), true
+}
+
+//Update(key, fn) calls fn with the value stored for key and whether key was
+//found. If fn returns keep == true, new is stored for key; otherwise key is
+//deleted. It returns the receiver if nothing changed, which is when fn
+//returns keep == false for a key that was not found, or returns a value
+//equal to old, compared the same way CompareAndSwap() compares values.
+func (ot *tree) Update(
+	key BptKey,
+	fn func(old interface{}, found bool) (new interface{}, keep bool),
+) BpTree {
+	oldLeaf, path := ot.findLeaf(key)
+	old, found := oldLeaf.get(key, ot.cmp)
+
+	new, keep := fn(old, found)
+	if (!keep && !found) || (keep && found && ot.valEquals(old, new)) {
+		return ot
+	}
+
+	t := ot.copy()
+	if keep {
+		t.putLeaf(oldLeaf, path, key, new)
+	} else {
+		t.delLeaf(oldLeaf, path, key)
+	}
+	return t.freeze()
+}
@@ -0,0 +1,97 @@
+package bptree
+
+//DiffKind says how an entry differs between two versions of a tree.
+type DiffKind int
+
+const (
+	//DiffAdded entries are only in the new tree.
+	DiffAdded DiffKind = iota
+	//DiffRemoved entries are only in the old tree.
+	DiffRemoved
+	//DiffChanged entries are in both trees, with different values.
+	DiffChanged
+)
+
+func (k DiffKind) String() string {
+	switch k {
+	case DiffAdded:
+		return "Added"
+	case DiffRemoved:
+		return "Removed"
+	case DiffChanged:
+		return "Changed"
+	}
+	return "DiffKind(?)"
+}
+
+//DiffEntry is one difference between two trees. OldVal is nil for
+//DiffAdded entries and NewVal is nil for DiffRemoved entries.
+type DiffEntry struct {
+	Kind   DiffKind
+	Key    BptKey
+	OldVal interface{}
+	NewVal interface{}
+}
+
+//Diff(old, new) returns the differences between two trees in ascending key
+//order. Values are compared the same way BpTree.Equals() compares them.
+//
+//The two trees are walked in parallel, and whenever both walks reach the
+//start of the same node the whole subtree is skipped, so diffing two
+//versions of a tree costs time in proportion to the nodes that differ
+//between them rather than to the size of the trees.
+func Diff(old, new BpTree) []DiffEntry {
+	return DiffFunc(old, new, nil)
+}
+
+//DiffFunc(old, new, eq) is Diff() with values compared by eq. A nil eq
+//compares values the same way Diff() does.
+func DiffFunc(old, new BpTree, eq func(a, b interface{}) bool) []DiffEntry {
+	var diffs []DiffEntry
+	walkDiff(old.(*tree), new.(*tree), eq, func(d DiffEntry) bool {
+		diffs = append(diffs, d)
+		return true
+	})
+	return diffs
+}
+
+//walkDiff calls fn for every difference between ot and nt in ascending key
+//order, until fn returns false.
+func walkDiff(ot, nt *tree, eq func(a, b interface{}) bool, fn func(DiffEntry) bool) {
+	if ot.root == nt.root {
+		return
+	}
+	if eq == nil {
+		eq = defaultValEquals
+	}
+
+	oi := ot.iter()
+	ni := nt.iter()
+	ook, nok := oi.Next(), ni.Next()
+	for ook || nok {
+		if ook && nok && skipShared(oi, ni) {
+			ook, nok = oi.valid(), ni.valid()
+			continue
+		}
+
+		var d DiffEntry
+		switch {
+		case !nok || (ook && ot.cmp(oi.Key(), ni.Key()) < 0):
+			d = DiffEntry{DiffRemoved, oi.Key(), oi.Val(), nil}
+			ook = oi.Next()
+		case !ook || ot.cmp(ni.Key(), oi.Key()) < 0:
+			d = DiffEntry{DiffAdded, ni.Key(), nil, ni.Val()}
+			nok = ni.Next()
+		default:
+			changed := !eq(oi.Val(), ni.Val())
+			d = DiffEntry{DiffChanged, ni.Key(), oi.Val(), ni.Val()}
+			ook, nok = oi.Next(), ni.Next()
+			if !changed {
+				continue
+			}
+		}
+		if !fn(d) {
+			return
+		}
+	}
+}
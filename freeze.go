@@ -0,0 +1,57 @@
+package bptree
+
+//The whole point of this package is that a published version of a tree,
+//meaning one that has been returned to the caller, is never modified; every
+//update copies the nodes it changes and leaves the originals alone. When
+//ASSERT is set that invariant is checked: each version's nodes are frozen as
+//the version is published, and every method that modifies a node in place
+//first calls assertMutable(), which panics if the node is frozen.
+
+//freeze marks every node reachable from t.root as frozen, when ASSERT is
+//set, and returns t. Subtrees are only ever frozen whole, so the walk stops
+//at nodes that are already frozen, and freezing a new version only visits
+//the nodes that were created to make it.
+func (t *Tree[K, V]) freeze() *Tree[K, V] {
+	if ASSERT {
+		freezeNode(t.root)
+	}
+	return t
+}
+
+func freezeNode[K, V any](node nodeI[K, V]) {
+	switch n := node.(type) {
+	case *leafNodeS[K, V]:
+		n.frozen = true
+	case *interiorNodeS[K, V]:
+		if n.frozen {
+			return
+		}
+		n.frozen = true
+		for _, kid := range n.vals {
+			freezeNode(kid)
+		}
+	}
+}
+
+func isFrozen[K, V any](node nodeI[K, V]) bool {
+	switch n := node.(type) {
+	case *leafNodeS[K, V]:
+		return n.frozen
+	case *interiorNodeS[K, V]:
+		return n.frozen
+	}
+	return false
+}
+
+//assertMutable panics if node belongs to a published version. op names the
+//operation that was about to modify node in place. Use it like assert():
+//
+//    if ASSERT {
+//        assertMutable(node, "interiorNodeS.insertAt")
+//    }
+//
+func assertMutable[K, V any](node nodeI[K, V], op string) {
+	if isFrozen(node) {
+		lgr.Panicf("ASSERT: %s: modifying node %p of a published version; it must be copied first; node=\n%v", op, node, node)
+	}
+}
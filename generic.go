@@ -0,0 +1,73 @@
+package bptree
+
+import (
+	"cmp"
+)
+
+//NewTree instantiates a new Tree for a given order, for a key type that is
+//cmp.Ordered. Keys are compared with cmp.Compare. See NewBpTree for a
+//discussion of the order.
+func NewTree[K cmp.Ordered, V any](order int) *Tree[K, V] {
+	return NewTreeFunc[K, V](order, cmp.Compare[K])
+}
+
+//NewTreeFunc instantiates a new Tree for a given order, ordering keys with
+//compare, which must return a negative number when a < b, zero when a == b
+//and a positive number when a > b.
+func NewTreeFunc[K any, V any](order int, compare func(a, b K) int) *Tree[K, V] {
+	if order < 3 {
+		lgr.Panic("Cannot make a Tree with lessthan order=3")
+	}
+	if compare == nil {
+		lgr.Panic("Cannot make a Tree with a nil compare function")
+	}
+	t := mkTree[K, V](order, compare)
+	return t.freeze()
+}
+
+//Put(key, val) returns a new Tree with key mapped to val, and a boolean that
+//is true if key was added rather than having its value replaced.
+func (ot *Tree[K, V]) Put(key K, val V) (*Tree[K, V], bool) {
+	oldLeaf, path := ot.findLeaf(key)
+
+	t := ot.copy()
+	_, added := t.putLeaf(oldLeaf, path, key, val)
+	return t.freeze(), added
+}
+
+//Del(key) returns a new Tree without key, the value that was stored for key,
+//and a boolean that indicates if key was found. If key was not found the
+//receiver itself is returned.
+func (ot *Tree[K, V]) Del(key K) (*Tree[K, V], V, bool) {
+	t := ot.copy()
+
+	val, removed := t.del(key)
+	if !removed {
+		return ot, val, removed
+	}
+
+	return t.freeze(), val, removed
+}
+
+//Equals does a deep equivalence check between trees; they are equal if they
+//hold the same keys mapped to the same values. Values are compared with eq,
+//or, if eq is nil, the same way BpTree.Equals() compares them. As with
+//BpTree.EqualsFunc(), subtrees that the two versions share are skipped
+//without being walked.
+func (t *Tree[K, V]) Equals(other *Tree[K, V], eq func(a, b V) bool) bool {
+	return t.equalsFunc(other, eq)
+}
+
+//Iter returns a TreeIter that walks every entry of the Tree in ascending key
+//order.
+func (t *Tree[K, V]) Iter() TreeIter[K, V] {
+	return t.iter()
+}
+
+//TreeIter is the typed version of BptIter for a Tree. Next() must be called
+//before Key() or Val(); it returns false once there are no more entries.
+type TreeIter[K any, V any] interface {
+	Next() bool
+	Key() K
+	Val() V
+}
@@ -0,0 +1,38 @@
+package bptree
+
+import (
+	"cmp"
+	"strconv"
+)
+
+//IntKey is a BptKey implementation for signed integers; construction is
+//simply IntKey(myint). IntKeys are ordered numerically, and compare
+//numerically with Uint64Keys too.
+type IntKey int64
+
+//Compare returns -1, 0 or 1 as the receiver is less than, equal to or
+//greater than the argument. Keys of other types are ordered by type.
+func (k0 IntKey) Compare(K1 BptKey) int {
+	switch k1 := K1.(type) {
+	case IntKey:
+		return cmp.Compare(k0, k1)
+	case Uint64Key:
+		return compareIntUint(int64(k0), uint64(k1))
+	}
+	return compareRanks(k0, K1)
+}
+
+//Equals is Compare(K1) == 0.
+func (k0 IntKey) Equals(K1 BptKey) bool {
+	return k0.Compare(K1) == 0
+}
+
+//LessThan is Compare(K1) < 0.
+func (k0 IntKey) LessThan(K1 BptKey) bool {
+	return k0.Compare(K1) < 0
+}
+
+//String Trivial.
+func (k IntKey) String() string {
+	return strconv.FormatInt(int64(k), 10)
+}
@@ -4,71 +4,91 @@ import (
 	"fmt"
 )
 
-type interiorNodeS struct {
-	keys []BptKey
-	vals []nodeI
+type interiorNodeS[K, V any] struct {
+	keys []K
+	vals []nodeI[K, V]
+	//cnts[i] is the number of entries in the subtree rooted at vals[i].
+	//It is kept in step with vals by every operation that modifies vals.
+	cnts   []int
+	edit   *editT //the transient edit session that owns this node, if any
+	frozen bool   //set by freeze() when ASSERT is set; see freeze.go
 }
 
-func mkNode(order int) *interiorNodeS {
+func mkNode[K, V any](order int) *interiorNodeS[K, V] {
 	//set the capacity of keys and vals one slot to much
 	//so the node can reach the isToBig() condition and be split
-	var node = new(interiorNodeS)
-	node.keys = make([]BptKey, 0, order)
-	node.vals = make([]nodeI, 0, order+1)
+	var node = new(interiorNodeS[K, V])
+	node.keys = make([]K, 0, order)
+	node.vals = make([]nodeI[K, V], 0, order+1)
+	node.cnts = make([]int, 0, order+1)
 	return node
 }
 
-func (node *interiorNodeS) copy() *interiorNodeS {
-	copyNode := mkNode(node.order())
+func (node *interiorNodeS[K, V]) copy() *interiorNodeS[K, V] {
+	copyNode := mkNode[K, V](node.order())
 	copyNode.keys = append(copyNode.keys, node.keys...)
 	copyNode.vals = append(copyNode.vals, node.vals...)
+	copyNode.cnts = append(copyNode.cnts, node.cnts...)
 	return copyNode
 }
 
-func (node *interiorNodeS) swapLeafNode(oldLeaf, newLeaf *leafNodeS) {
+func (node *interiorNodeS[K, V]) swapLeafNode(oldLeaf, newLeaf *leafNodeS[K, V]) {
+	if ASSERT {
+		assertMutable(node, "interiorNodeS.swapLeafNode")
+	}
 	for i, n := range node.vals {
-		ln := n.(*leafNodeS)
+		ln := n.(*leafNodeS[K, V])
 		if oldLeaf == ln {
 			node.vals[i] = newLeaf
+			node.cnts[i] = newLeaf.count()
 			return
 		}
 	}
 	lgr.Panicf("swapLeafNode: did not find oldLeaf=%p to swap for newLeaf=%p; node=\n%v", oldLeaf, newLeaf, node)
 }
 
-func (node *interiorNodeS) swapInteriorNode(oldNode, newNode *interiorNodeS) {
+func (node *interiorNodeS[K, V]) swapInteriorNode(oldNode, newNode *interiorNodeS[K, V]) {
+	if ASSERT {
+		assertMutable(node, "interiorNodeS.swapInteriorNode")
+	}
 	for i, n := range node.vals {
-		ln := n.(*interiorNodeS)
+		ln := n.(*interiorNodeS[K, V])
 		if oldNode == ln {
 			node.vals[i] = newNode
+			node.cnts[i] = newNode.count()
 			return
 		}
 	}
 	lgr.Panicf("swapNodeNode: did not find oldNode=%p to swap for newNode=%p; node=\n%v", oldNode, newNode, node)
 }
 
-func (node *interiorNodeS) swapNode(oldNode, newNode nodeI) {
+func (node *interiorNodeS[K, V]) swapNode(oldNode, newNode nodeI[K, V]) {
+	if ASSERT {
+		assertMutable(node, "interiorNodeS.swapNode")
+	}
 	if oldNode.isLeaf() {
-		oleaf := oldNode.(*leafNodeS)
-		nleaf := newNode.(*leafNodeS) //let it panic on failed casting
+		oleaf := oldNode.(*leafNodeS[K, V])
+		nleaf := newNode.(*leafNodeS[K, V]) //let it panic on failed casting
 
 		for i, n := range node.vals {
-			nl := n.(*leafNodeS) //another panic on failed casting
+			nl := n.(*leafNodeS[K, V]) //another panic on failed casting
 			if oleaf == nl {
-				node.vals[i] = nodeI(nleaf)
+				node.vals[i] = nodeI[K, V](nleaf)
+				node.cnts[i] = nleaf.count()
 				return
 			}
 		}
 		lgr.Panicf("swapNode: did not find oleaf=%p to swap for nleaf=%p; node=\n%v", oleaf, nleaf, node)
 	} else {
-		onode := oldNode.(*interiorNodeS)
-		nnode := newNode.(*interiorNodeS) //let it panic on failed casting
+		onode := oldNode.(*interiorNodeS[K, V])
+		nnode := newNode.(*interiorNodeS[K, V]) //let it panic on failed casting
 
 		for i, n := range node.vals {
-			nn := n.(*interiorNodeS) //another panic on failed casting
+			nn := n.(*interiorNodeS[K, V]) //another panic on failed casting
 
 			if onode == nn {
-				node.vals[i] = nodeI(nnode)
+				node.vals[i] = nodeI[K, V](nnode)
+				node.cnts[i] = nnode.count()
 				return
 			}
 		}
@@ -78,23 +98,26 @@ func (node *interiorNodeS) swapNode(oldNode, newNode nodeI) {
 	return
 }
 
-func (node *interiorNodeS) swapKey(oldKey, newKey BptKey) {
+func (node *interiorNodeS[K, V]) swapKey(oldKey, newKey K, cmp compareFunc[K]) {
+	if ASSERT {
+		assertMutable(node, "interiorNodeS.swapKey")
+	}
 	for i, k := range node.keys {
-		if oldKey.Equals(k) {
+		if cmp(oldKey, k) == 0 {
 			node.keys[i] = newKey
 			return
 		}
 	}
-	lgr.Panicf("swapKey: did not find oldKey=%q to swap for newKey=%q; node=\n%v", oldKey, newKey, node)
+	lgr.Panicf("swapKey: did not find oldKey=%v to swap for newKey=%v; node=\n%v", oldKey, newKey, node)
 }
 
-func (node *interiorNodeS) String() string {
+func (node *interiorNodeS[K, V]) String() string {
 	s := ""
 	s += fmt.Sprintf("%p: NODE: len(node.keys)=%d; cap(node.keys)=%d; len(node.vals)=%d; cap(node.vals)=%d;\n", node, len(node.keys), cap(node.keys), len(node.vals), cap(node.vals))
 	s += fmt.Sprintf("%p: keys = ", node)
 	keys := make([]string, 0, 2)
 	for _, key := range node.keys {
-		keys = append(keys, fmt.Sprintf("%q", key.String()))
+		keys = append(keys, fmt.Sprintf("%q", fmt.Sprint(key)))
 	}
 	s += fmt.Sprintf("%v\n", keys)
 
@@ -103,20 +126,22 @@ func (node *interiorNodeS) String() string {
 	for _, v := range node.vals {
 		//redundent; But hey, Type Safety! WooHoo! NOT!!!
 		if v.isLeaf() {
-			nv := v.(*leafNodeS)
+			nv := v.(*leafNodeS[K, V])
 			vals = append(vals, fmt.Sprintf("%p", nv))
 		} else {
-			nv := v.(*interiorNodeS)
+			nv := v.(*interiorNodeS[K, V])
 			vals = append(vals, fmt.Sprintf("%p", nv))
 		}
 	}
 	s += fmt.Sprintf("%v\n", vals)
+
+	s += fmt.Sprintf("%p: cnts = %v\n", node, node.cnts)
 	s += "\n"
 	return s
 }
 
-func (l *interiorNodeS) equals(rn nodeI) bool {
-	r, ok := rn.(*interiorNodeS)
+func (l *interiorNodeS[K, V]) equals(rn nodeI[K, V]) bool {
+	r, ok := rn.(*interiorNodeS[K, V])
 	if !ok {
 		return false //not the same type; clearly not equal.
 	}
@@ -125,7 +150,7 @@ func (l *interiorNodeS) equals(rn nodeI) bool {
 
 //Only called after a val splits. So new key, val pair will be a new half of
 //one of the vals.
-func (node *interiorNodeS) insert(key BptKey, val nodeI) {
+func (node *interiorNodeS[K, V]) insert(key K, val nodeI[K, V], cmp compareFunc[K]) {
 	//The only relation between node.keys[i] and node.vals[i] is that
 	//node.keys[i] is strictly greater than any key in or below node.vals[i].
 	//
@@ -133,26 +158,54 @@ func (node *interiorNodeS) insert(key BptKey, val nodeI) {
 	//to node.vals[i+1] where key may be found in val (or its decendents),
 	//So we insert key into node.keys[i] and val into node.val[i+1].
 	//
-	var i int
-	for i = 0; i < len(node.keys); i++ {
-		if key.LessThan(node.keys[i]) {
-			node.keys = append(node.keys[:i+1], node.keys[i:]...)
-			//For interior nodes len(node.keys) == len(node.vals)-1 holds,
-			//so this can not produce a "index out of range" error.
-			node.vals = append(node.vals[:i+2], node.vals[i+1:]...)
-			node.keys[i] = key
-			node.vals[i+1] = val
-			return
-		}
+	i := searchNode(node.keys, key, cmp)
+	//if i == len(node.keys) it must have been the last val that split so
+	//this is valid because the new key is greater than the last val and
+	//less than or equal to the new val inserted.
+	node.insertAt(i, key, val)
+}
+
+//insertAt inserts key into node.keys[i] and val into node.vals[i+1], for
+//callers that already know where they go.
+func (node *interiorNodeS[K, V]) insertAt(i int, key K, val nodeI[K, V]) {
+	if ASSERT {
+		assertMutable(node, "interiorNodeS.insertAt")
 	}
-	//must have been the last val that split so this is valid because the
-	//new key is greater than the last val and less than or equal to the
-	//new val inserted.
-	//if i == len(node.keys) {
-	node.keys = append(node.keys, key)
-	node.vals = append(node.vals, val)
-	return
-	//}
+	if i == len(node.keys) {
+		node.keys = append(node.keys, key)
+		node.vals = append(node.vals, val)
+		node.cnts = append(node.cnts, val.count())
+		return
+	}
+	node.keys = append(node.keys[:i+1], node.keys[i:]...)
+	//For interior nodes len(node.keys) == len(node.vals)-1 holds,
+	//so this can not produce a "index out of range" error.
+	node.vals = append(node.vals[:i+2], node.vals[i+1:]...)
+	node.cnts = append(node.cnts[:i+2], node.cnts[i+1:]...)
+	node.keys[i] = key
+	node.vals[i+1] = val
+	node.cnts[i+1] = val.count()
+}
+
+//removeAt removes node.vals[i] and node.keys[i-1], the key that separates
+//it from node.vals[i-1]; it undoes insertAt(i-1, key, val). i must be > 0.
+func (node *interiorNodeS[K, V]) removeAt(i int) {
+	if ASSERT {
+		assertMutable(node, "interiorNodeS.removeAt")
+	}
+	node.keys = append(node.keys[:i-1], node.keys[i:]...)
+	node.vals = append(node.vals[:i], node.vals[i+1:]...)
+	node.cnts = append(node.cnts[:i], node.cnts[i+1:]...)
+}
+
+//setChild replaces node.vals[i] with child, and updates node.cnts[i] to
+//match.
+func (node *interiorNodeS[K, V]) setChild(i int, child nodeI[K, V]) {
+	if ASSERT {
+		assertMutable(node, "interiorNodeS.setChild")
+	}
+	node.vals[i] = child
+	node.cnts[i] = child.count()
 }
 
 // isToBig() was isFull, but that was a misnomer I got from the wikipedia post
@@ -161,11 +214,11 @@ func (node *interiorNodeS) insert(key BptKey, val nodeI) {
 // that same page, then nodes/leafs must be allowed to get bigger than the
 // order allows and thus qualify for the SPLIT operation. That condition
 // should be TOBIG not FULL.
-func (node *interiorNodeS) isToBig() bool {
+func (node *interiorNodeS[K, V]) isToBig() bool {
 	return len(node.keys) == cap(node.keys)
 }
 
-func (n *interiorNodeS) isToSmall() bool {
+func (n *interiorNodeS[K, V]) isToSmall() bool {
 	return n.size() < n.halfFullSize()
 }
 
@@ -173,16 +226,19 @@ func (n *interiorNodeS) isToSmall() bool {
 // Leaving the original node shrunk by half and returning the new right half
 // (minus the MIDDLE key) and the MIDDLE key (of the original overlarge
 // node).
-func (lNode *interiorNodeS) split() (*interiorNodeS, BptKey) {
+func (lNode *interiorNodeS[K, V]) split() (*interiorNodeS[K, V], K) {
+	if ASSERT {
+		assertMutable(lNode, "interiorNodeS.split")
+	}
 	order := lNode.order()
-	rNode := mkNode(order)
+	rNode := mkNode[K, V](order)
 
 	//keySplitIdx := len(lNode.keys) / 2 //len(lNode.keys) == order
 	//valSplitIdx := len(lNode.vals) / 2 //len(lNode.vals) == order+1
 	keySplitIdx := order / 2
 	valSplitIdx := (order + 1) / 2
 
-	var midKey BptKey
+	var midKey K
 	//if len(lNode.keys)%2 == 1 {
 	if order%2 == 1 {
 		//order is ODD eg 3, 5, 7 etc
@@ -190,10 +246,12 @@ func (lNode *interiorNodeS) split() (*interiorNodeS, BptKey) {
 
 		rNode.keys = append(rNode.keys, lNode.keys[keySplitIdx+1:]...)
 		rNode.vals = append(rNode.vals, lNode.vals[valSplitIdx:]...)
+		rNode.cnts = append(rNode.cnts, lNode.cnts[valSplitIdx:]...)
 
 		//preserve the cap(lNode.keys) and cap(lNode.vals)
 		lNode.keys = append(lNode.keys[:0], lNode.keys[:keySplitIdx]...)
 		lNode.vals = append(lNode.vals[:0], lNode.vals[:valSplitIdx]...)
+		lNode.cnts = append(lNode.cnts[:0], lNode.cnts[:valSplitIdx]...)
 	} else {
 		//order is EVEN eg 4, 6, 8 etc
 		//the right side is fatter
@@ -201,10 +259,12 @@ func (lNode *interiorNodeS) split() (*interiorNodeS, BptKey) {
 
 		rNode.keys = append(rNode.keys, lNode.keys[keySplitIdx:]...)
 		rNode.vals = append(rNode.vals, lNode.vals[valSplitIdx:]...)
+		rNode.cnts = append(rNode.cnts, lNode.cnts[valSplitIdx:]...)
 
 		//preserve the cap(lNode.keys) and cap(lNode.vals)
 		lNode.keys = append(lNode.keys[:0], lNode.keys[:keySplitIdx-1]...)
 		lNode.vals = append(lNode.vals[:0], lNode.vals[:valSplitIdx]...)
+		lNode.cnts = append(lNode.cnts[:0], lNode.cnts[:valSplitIdx]...)
 	}
 
 	//*** Finding the middle Key ***
@@ -222,50 +282,67 @@ func (lNode *interiorNodeS) split() (*interiorNodeS, BptKey) {
 	return rNode, midKey
 }
 
-func (rNode *interiorNodeS) findPeerLeft(parent *interiorNodeS) (*interiorNodeS, BptKey) {
-	var leftPeerNode *interiorNodeS
-	var leftPeerKey BptKey
+func (rNode *interiorNodeS[K, V]) findPeerLeft(parent *interiorNodeS[K, V]) (*interiorNodeS[K, V], K) {
+	var leftPeerNode *interiorNodeS[K, V]
+	var leftPeerKey K
 	var i int
 	for i = 0; i < len(parent.vals); i++ {
 		if rNode.equals(parent.vals[i]) {
 			if i == 0 {
 				//there is no left peer
-				return nil, nil
+				return nil, leftPeerKey
 			}
-			leftPeerNode = parent.vals[i-1].(*interiorNodeS)
+			leftPeerNode = parent.vals[i-1].(*interiorNodeS[K, V])
 			leftPeerKey = parent.keys[i-1]
 			return leftPeerNode, leftPeerKey
 		}
 	}
 	lgr.Panic("findPeerLeft: didn't find rNode(receiver) in parent")
-	return nil, nil
+	return nil, leftPeerKey
 }
 
-func (lNode *interiorNodeS) findPeerRight(parent *interiorNodeS) (*interiorNodeS, BptKey) {
-	var rightPeerNode *interiorNodeS
-	var rightPeerKey BptKey
+func (lNode *interiorNodeS[K, V]) findPeerRight(parent *interiorNodeS[K, V]) (*interiorNodeS[K, V], K) {
+	var rightPeerNode *interiorNodeS[K, V]
+	var rightPeerKey K
 	var i int
 	for i = 0; i < len(parent.vals); i++ {
 		if lNode.equals(parent.vals[i]) {
 			if i == len(parent.vals)-1 {
 				//there is no right peer
-				return nil, nil
+				return nil, rightPeerKey
 			}
-			rightPeerNode = parent.vals[i+1].(*interiorNodeS)
+			rightPeerNode = parent.vals[i+1].(*interiorNodeS[K, V])
 			rightPeerKey = parent.keys[i]
 			return rightPeerNode, rightPeerKey
 		}
 	}
 	lgr.Panic("findPeerRight: didn't find lNode(receiver) in parent")
-	return nil, nil
+	return nil, rightPeerKey
 }
 
-func (rNode *interiorNodeS) stealLeft(lNode *interiorNodeS) {
+//findChildIdx returns the index in node.vals of child, or -1 if child is not
+//one of node's children.
+func (node *interiorNodeS[K, V]) findChildIdx(child nodeI[K, V]) int {
+	for i, n := range node.vals {
+		if n == child {
+			return i
+		}
+	}
+	return -1
+}
+
+func (rNode *interiorNodeS[K, V]) stealLeft(lNode *interiorNodeS[K, V]) {
+	if ASSERT {
+		assertMutable(rNode, "interiorNodeS.stealLeft")
+		assertMutable(lNode, "interiorNodeS.stealLeft")
+	}
 	//stolenKey := lNode.keys[len(lNode.keys)-1]
 	stolenVal := lNode.vals[len(lNode.vals)-1]
+	stolenCnt := lNode.cnts[len(lNode.cnts)-1]
 	//this preserves cap(lNode.keys) and cap(lNode.vals)
 	lNode.keys = append(lNode.keys[:0], lNode.keys[:len(lNode.keys)-1]...)
 	lNode.vals = append(lNode.vals[:0], lNode.vals[:len(lNode.vals)-1]...)
+	lNode.cnts = append(lNode.cnts[:0], lNode.cnts[:len(lNode.cnts)-1]...)
 
 	//Before modifying rNode what was its leastKey
 	leastKey := rNode.findLeftMostKey()
@@ -275,32 +352,44 @@ func (rNode *interiorNodeS) stealLeft(lNode *interiorNodeS) {
 
 	//unshift operation that preserves cap(rNode.vals)
 	rNode.vals = append(rNode.vals[:0],
-		append([]nodeI{stolenVal}, rNode.vals...)...)
+		append([]nodeI[K, V]{stolenVal}, rNode.vals...)...)
+	rNode.cnts = append(rNode.cnts[:0],
+		append([]int{stolenCnt}, rNode.cnts...)...)
 
 	//unshift operation that preserves cap(rNode.keys)
 	rNode.keys = append(rNode.keys[:0],
-		append([]BptKey{leastKey}, rNode.keys...)...)
+		append([]K{leastKey}, rNode.keys...)...)
 
 	return
 }
 
-func (lNode *interiorNodeS) stealRight(rNode *interiorNodeS) {
+func (lNode *interiorNodeS[K, V]) stealRight(rNode *interiorNodeS[K, V]) {
+	if ASSERT {
+		assertMutable(lNode, "interiorNodeS.stealRight")
+		assertMutable(rNode, "interiorNodeS.stealRight")
+	}
 	//stolenKey := rNode.keys[0]
 	stolenNode := rNode.vals[0]
+	stolenCnt := rNode.cnts[0]
 
 	//this preserves cap(rNode.keys) and cap(rNode.vals)
 	rNode.keys = append(rNode.keys[:0], rNode.keys[1:]...)
 	rNode.vals = append(rNode.vals[:0], rNode.vals[1:]...)
+	rNode.cnts = append(rNode.cnts[:0], rNode.cnts[1:]...)
 
 	leastKey := stolenNode.findLeftMostKey()
 
 	lNode.keys = append(lNode.keys, leastKey)
 	lNode.vals = append(lNode.vals, stolenNode)
+	lNode.cnts = append(lNode.cnts, stolenCnt)
 
 	return
 }
 
-func (lNode *interiorNodeS) mergeRight(rNode *interiorNodeS) {
+func (lNode *interiorNodeS[K, V]) mergeRight(rNode *interiorNodeS[K, V]) {
+	if ASSERT {
+		assertMutable(lNode, "interiorNodeS.mergeRight")
+	}
 	leastKey := rNode.findLeftMostKey()
 
 	//For some reason you can't do the following append(...)
@@ -309,27 +398,28 @@ func (lNode *interiorNodeS) mergeRight(rNode *interiorNodeS) {
 	lNode.keys = append(lNode.keys, leastKey)
 	lNode.keys = append(lNode.keys, rNode.keys...)
 	lNode.vals = append(lNode.vals, rNode.vals...)
+	lNode.cnts = append(lNode.cnts, rNode.cnts...)
 
 	return
 }
 
-func (node *interiorNodeS) isLeaf() bool {
+func (node *interiorNodeS[K, V]) isLeaf() bool {
 	//FIXME: should remove these assertf() calls
 	assertf(cap(node.keys)+1 == cap(node.vals), "cap(node.keys)+1,%d != cap(node.vals),%d", cap(node.keys)+1, cap(node.vals))
 	assertf(len(node.keys)+1 == len(node.vals), "len(node.keys)+1,%d != len(node.vals),%d", len(node.keys)+1, len(node.vals))
 	return false
 }
 
-func (node_ *interiorNodeS) findLeftMostLeaf() *leafNodeS {
-	node := nodeI(node_)
+func (node_ *interiorNodeS[K, V]) findLeftMostLeaf() *leafNodeS[K, V] {
+	node := nodeI[K, V](node_)
 	for !node.isLeaf() {
-		node_ = node.(*interiorNodeS)
+		node_ = node.(*interiorNodeS[K, V])
 		node = node_.vals[0]
 	}
-	return node.(*leafNodeS)
+	return node.(*leafNodeS[K, V])
 }
 
-func (node_ *interiorNodeS) findLeftMostKey() BptKey {
+func (node_ *interiorNodeS[K, V]) findLeftMostKey() K {
 	//node := nodeI(node_)
 	//for !node.isLeaf() {
 	//	node_ = node.(*interiorNodeS)
@@ -340,16 +430,25 @@ func (node_ *interiorNodeS) findLeftMostKey() BptKey {
 	return leaf.findLeftMostKey()
 }
 
-func (node *interiorNodeS) order() int {
+func (node *interiorNodeS[K, V]) order() int {
 	//in both leaf and interior nodes; see mkLeaf && mkNode
 	return cap(node.keys)
 }
 
-func (node *interiorNodeS) size() int {
+func (node *interiorNodeS[K, V]) size() int {
 	return len(node.vals)
 }
 
-func (node *interiorNodeS) halfFullSize() int {
+//count returns the number of entries in the subtree rooted at node.
+func (node *interiorNodeS[K, V]) count() int {
+	var n int
+	for _, c := range node.cnts {
+		n += c
+	}
+	return n
+}
+
+func (node *interiorNodeS[K, V]) halfFullSize() int {
 	// int(math.Ceil(float64(order)/2)) == (order+1)/2 (in integer math)
 
 	//For interior nodes halfFullSize == math.Ceil( float64(order)/2 )
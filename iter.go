@@ -0,0 +1,393 @@
+package bptree
+
+//BptIter is a cursor over the entries of a BpTree. A new BptIter is
+//positioned before its first entry, so Next() must be called before Key()
+//or Val(). Next() returns false once there are no more entries.
+//
+//A BptIter walks the version of the tree it was created from. Because the
+//tree is persistent, Put() and Del() calls made on that version (or on any
+//version derived from it) never change what the BptIter sees.
+//
+type BptIter interface {
+	Next() bool
+	Key() BptKey
+	Val() interface{}
+}
+
+//iterS walks the leaves left-to-right, or right-to-left if reverse is set.
+//Leaves have no sibling pointers in this persistent design, so the iterator
+//keeps its own root-to-leaf path, along with the index of the child taken at
+//each level, and climbs back up the path to find the neighbouring leaf.
+type iterS[K, V any] struct {
+	path    pathT[K, V]      //interior nodes from the root down to leaf's parent
+	idxs    []int            //idxs[i] is the index in path[i].vals of the child taken
+	leaf    *leafNodeS[K, V] //current leaf; nil once the iterator is exhausted
+	idx     int              //index of the current entry in leaf
+	started bool
+	reverse bool
+}
+
+//mkIter creates an iterator whose first call to Next() lands on
+//leaf.keys[idx] (or the first entry after it, if idx is off the end of
+//leaf). path must be the root-to-leaf path for leaf as returned by
+//t.findLeaf() and friends; the iterator takes ownership of it.
+func mkIter[K, V any](leaf *leafNodeS[K, V], path pathT[K, V], idx int) *iterS[K, V] {
+	return mkIterDir(leaf, path, idx, false)
+}
+
+//mkRevIter creates a reverse iterator whose first call to Next() lands on
+//leaf.keys[idx] (or the first entry before it, if idx is -1). See mkIter.
+func mkRevIter[K, V any](leaf *leafNodeS[K, V], path pathT[K, V], idx int) *iterS[K, V] {
+	return mkIterDir(leaf, path, idx, true)
+}
+
+func mkIterDir[K, V any](leaf *leafNodeS[K, V], path pathT[K, V], idx int, reverse bool) *iterS[K, V] {
+	it := new(iterS[K, V])
+	it.path = path
+	it.idxs = make([]int, len(path))
+	it.leaf = leaf
+	it.idx = idx
+	it.reverse = reverse
+
+	for i := range path {
+		var child nodeI[K, V] = leaf
+		if i+1 < len(path) {
+			child = path[i+1]
+		}
+		it.idxs[i] = path[i].findChildIdx(child)
+		if ASSERT {
+			assertf(it.idxs[i] >= 0, "mkIter: path[%d]=%p is not the parent of %p", i, path[i], child)
+		}
+	}
+
+	return it
+}
+
+//Next advances the iterator to the next entry. It returns false when there
+//are no more entries.
+func (it *iterS[K, V]) Next() bool {
+	if it.leaf == nil {
+		return false
+	}
+	if it.reverse {
+		return it.prev()
+	}
+	if it.started {
+		it.idx++
+	} else {
+		it.started = true
+	}
+	for it.idx >= len(it.leaf.keys) {
+		if !it.nextLeaf() {
+			it.leaf = nil
+			return false
+		}
+	}
+	return true
+}
+
+func (it *iterS[K, V]) prev() bool {
+	if it.started {
+		it.idx--
+	} else {
+		it.started = true
+	}
+	for it.idx < 0 {
+		if !it.prevLeaf() {
+			it.leaf = nil
+			return false
+		}
+	}
+	return true
+}
+
+//Key returns the key of the current entry.
+func (it *iterS[K, V]) Key() K {
+	if it.leaf == nil || !it.started {
+		var zero K
+		return zero
+	}
+	return it.leaf.keys[it.idx]
+}
+
+//Val returns the value of the current entry.
+func (it *iterS[K, V]) Val() V {
+	if it.leaf == nil || !it.started {
+		var zero V
+		return zero
+	}
+	return it.leaf.vals[it.idx]
+}
+
+//nextLeaf moves the iterator to the first entry of the leaf to the right of
+//the current one. It returns false if the current leaf is the right most
+//leaf of the tree.
+func (it *iterS[K, V]) nextLeaf() bool {
+	for !it.path.isEmpty() {
+		parent := it.path.peek()
+		last := len(it.idxs) - 1
+		if it.idxs[last]+1 < len(parent.vals) {
+			it.idxs[last]++
+			it.descendLeft(parent.vals[it.idxs[last]])
+			return true
+		}
+		it.path.pop()
+		it.idxs = it.idxs[:last]
+	}
+	return false
+}
+
+//prevLeaf moves the iterator to the last entry of the leaf to the left of
+//the current one. It returns false if the current leaf is the left most leaf
+//of the tree.
+func (it *iterS[K, V]) prevLeaf() bool {
+	for !it.path.isEmpty() {
+		parent := it.path.peek()
+		last := len(it.idxs) - 1
+		if it.idxs[last] > 0 {
+			it.idxs[last]--
+			it.descendRight(parent.vals[it.idxs[last]])
+			return true
+		}
+		it.path.pop()
+		it.idxs = it.idxs[:last]
+	}
+	return false
+}
+
+//descendRight pushes node and its right most decendents onto the path until
+//it reaches a leaf, which becomes the current leaf.
+func (it *iterS[K, V]) descendRight(node nodeI[K, V]) {
+	for !node.isLeaf() {
+		curNode := node.(*interiorNodeS[K, V])
+		it.path.push(curNode)
+		it.idxs = append(it.idxs, len(curNode.vals)-1)
+		node = curNode.vals[len(curNode.vals)-1]
+	}
+	it.leaf = node.(*leafNodeS[K, V])
+	it.idx = len(it.leaf.keys) - 1
+}
+
+//descendLeft pushes node and its left most decendents onto the path until it
+//reaches a leaf, which becomes the current leaf.
+func (it *iterS[K, V]) descendLeft(node nodeI[K, V]) {
+	for !node.isLeaf() {
+		curNode := node.(*interiorNodeS[K, V])
+		it.path.push(curNode)
+		it.idxs = append(it.idxs, 0)
+		node = curNode.vals[0]
+	}
+	it.leaf = node.(*leafNodeS[K, V])
+	it.idx = 0
+}
+
+//Iter returns a BptIter that walks every entry of the tree in ascending key
+//order.
+//
+func (t *tree) Iter() BptIter {
+	return t.iter()
+}
+
+func (t *Tree[K, V]) iter() *iterS[K, V] {
+	leaf, path := t.findLeftMostLeaf()
+	return mkIter(leaf, path, 0)
+}
+
+//RevIter returns a BptIter that walks every entry of the tree in descending
+//key order.
+//
+func (t *tree) RevIter() BptIter {
+	return t.revIter()
+}
+
+func (t *Tree[K, V]) revIter() *iterS[K, V] {
+	leaf, path := t.findRightMostLeaf()
+	return mkRevIter(leaf, path, len(leaf.keys)-1)
+}
+
+//rangeIterS wraps an iterS that was seeked to one end of a range, and stops
+//it at the other end of the range; the high end for forward iterators and
+//the low end for reverse iterators.
+type rangeIterS[K, V any] struct {
+	it     *iterS[K, V]
+	end    K
+	hasEnd bool //false means unbounded
+	endInc bool
+	done   bool
+	cmp    compareFunc[K]
+}
+
+//Next advances the iterator to the next entry in the range. It returns false
+//when there are no more entries in the range.
+func (r *rangeIterS[K, V]) Next() bool {
+	if r.done {
+		return false
+	}
+	if !r.it.Next() {
+		r.done = true
+		return false
+	}
+	if r.hasEnd {
+		c := r.cmp(r.it.Key(), r.end)
+		if r.it.reverse {
+			c = -c
+		}
+		if c > 0 || (!r.endInc && c == 0) {
+			r.done = true
+			return false
+		}
+	}
+	return true
+}
+
+//Key returns the key of the current entry.
+func (r *rangeIterS[K, V]) Key() K {
+	if r.done {
+		var zero K
+		return zero
+	}
+	return r.it.Key()
+}
+
+//Val returns the value of the current entry.
+func (r *rangeIterS[K, V]) Val() V {
+	if r.done {
+		var zero V
+		return zero
+	}
+	return r.it.Val()
+}
+
+//Range returns a BptIter that walks, in ascending key order, the entries
+//whose keys fall between lo and hi. loInc and hiInc control whether lo and
+//hi themselves are included in the range. A nil lo or hi leaves that end of
+//the range unbounded (loInc or hiInc is ignored for that end). For example:
+//
+//    t.Range(lo, true, hi, false) //lo <= key < hi
+//    t.Range(lo, false, nil, false) //lo < key
+//
+//Range seeks directly to lo, so walking k entries costs O(log n + k).
+//
+func (t *tree) Range(lo BptKey, loInc bool, hi BptKey, hiInc bool) BptIter {
+	return t.rangeIter(lo, lo != nil, loInc, hi, hi != nil, hiInc)
+}
+
+//rangeIter is Range() with the bounds that are set flagged by hasLo and
+//hasHi, since a K has no nil to leave an end of the range unbounded.
+func (t *Tree[K, V]) rangeIter(lo K, hasLo, loInc bool, hi K, hasHi, hiInc bool) *rangeIterS[K, V] {
+	var it *iterS[K, V]
+	if !hasLo {
+		leaf, path := t.findLeftMostLeaf()
+		it = mkIter(leaf, path, 0)
+	} else {
+		leaf, path := t.findLeaf(lo)
+		i, found := searchLeaf(leaf.keys, lo, t.cmp)
+		if found && !loInc {
+			i++
+		}
+		//if i == len(leaf.keys) the iterator moves on to the next leaf
+		it = mkIter(leaf, path, i)
+	}
+	return &rangeIterS[K, V]{it: it, end: hi, hasEnd: hasHi, endInc: hiInc, cmp: t.cmp}
+}
+
+//RevRange is the descending version of Range. It returns a BptIter that
+//walks the entries whose keys fall between lo and hi starting from hi and
+//ending at lo. The bounds are interpreted exactly as they are for Range; for
+//example, the latest n entries before a cutoff are the first n entries of:
+//
+//    t.RevRange(nil, false, cutoff, false) //key < cutoff, descending
+//
+func (t *tree) RevRange(lo BptKey, loInc bool, hi BptKey, hiInc bool) BptIter {
+	return t.revRangeIter(lo, lo != nil, loInc, hi, hi != nil, hiInc)
+}
+
+//revRangeIter is RevRange() with the bounds flagged as for rangeIter().
+func (t *Tree[K, V]) revRangeIter(lo K, hasLo, loInc bool, hi K, hasHi, hiInc bool) *rangeIterS[K, V] {
+	var it *iterS[K, V]
+	if !hasHi {
+		leaf, path := t.findRightMostLeaf()
+		it = mkRevIter(leaf, path, len(leaf.keys)-1)
+	} else {
+		leaf, path := t.findLeaf(hi)
+		i, found := searchLeaf(leaf.keys, hi, t.cmp)
+		if !found || !hiInc {
+			i--
+		}
+		//if i == -1 the iterator moves on to the previous leaf
+		it = mkRevIter(leaf, path, i)
+	}
+	return &rangeIterS[K, V]{it: it, end: lo, hasEnd: hasLo, endInc: loInc, cmp: t.cmp}
+}
+
+//valid returns true if the iterator is positioned on an entry.
+func (it *iterS[K, V]) valid() bool {
+	return it.leaf != nil && it.started
+}
+
+//startLevel returns the highest level of the iterator's path whose node
+//starts at the current entry; len(it.path) stands for the leaf itself. It
+//returns -1 if the current entry is not the first entry of the leaf. Only
+//forward iterators are supported.
+func (it *iterS[K, V]) startLevel() int {
+	if !it.valid() || it.idx != 0 {
+		return -1
+	}
+	lvl := len(it.path)
+	for lvl > 0 && it.idxs[lvl-1] == 0 {
+		lvl--
+	}
+	return lvl
+}
+
+//nodeAt returns the node at a level of the iterator's path, as numbered by
+//startLevel.
+func (it *iterS[K, V]) nodeAt(lvl int) nodeI[K, V] {
+	if lvl == len(it.path) {
+		return it.leaf
+	}
+	return it.path[lvl]
+}
+
+//skip moves a forward iterator that is positioned on the first entry of the
+//node at lvl to the first entry after that node's subtree, as if Next() had
+//been called once for every entry in the subtree.
+func (it *iterS[K, V]) skip(lvl int) {
+	it.path = it.path[:lvl]
+	it.idxs = it.idxs[:lvl]
+	if !it.nextLeaf() {
+		it.leaf = nil
+	}
+}
+
+//sharedStart checks whether a and b, two forward iterators, are both at the
+//start of the same node, in which case the entries below it are identical in
+//both. It returns the levels of the highest such node in a and in b, or -1
+//and -1 if there is none.
+func sharedStart[K, V any](a, b *iterS[K, V]) (int, int) {
+	alvl, blvl := a.startLevel(), b.startLevel()
+	if alvl < 0 || blvl < 0 {
+		return -1, -1
+	}
+	for i := alvl; i <= len(a.path); i++ {
+		an := a.nodeAt(i)
+		for j := blvl; j <= len(b.path); j++ {
+			if an == b.nodeAt(j) {
+				return i, j
+			}
+		}
+	}
+	return -1, -1
+}
+
+//skipShared skips the node found by sharedStart(a, b) in both iterators, and
+//returns true if there was one.
+func skipShared[K, V any](a, b *iterS[K, V]) bool {
+	alvl, blvl := sharedStart(a, b)
+	if alvl < 0 {
+		return false
+	}
+	a.skip(alvl)
+	b.skip(blvl)
+	return true
+}
@@ -0,0 +1,62 @@
+package bptree
+
+//keyRank orders the provided BptKey types relative to one another, so that
+//comparing keys of two different types gives a consistent answer, and a
+//tree holding several key types still has a total order. IntKey and
+//Uint64Key share a rank because they are compared by numeric value. Key
+//types this package does not provide all get otherKeyRank; see compareRanks.
+func keyRank(k BptKey) int {
+	switch k.(type) {
+	case IntKey, Uint64Key:
+		return 0
+	case TimeKey:
+		return 1
+	case BytesKey:
+		return 2
+	case LexStringKey:
+		return 3
+	case StringKey:
+		return 4
+	case TupleKey:
+		return 5
+	case Desc:
+		return 6
+	}
+	return otherKeyRank
+}
+
+//otherKeyRank is the keyRank of every key type this package does not
+//provide.
+const otherKeyRank = 7
+
+//compareRanks is the three way comparison of the ranks of a, one of this
+//package's keys, and b, a key of a different type. If b is of a type this
+//package does not provide, b is asked to order itself against a instead,
+//the same way defaultCompare(b, a) would, so that the two keys are ordered
+//the same way whichever one is compared to the other. Such a key type must
+//then not defer back to a's methods when ordering itself against a.
+func compareRanks(a, b BptKey) int {
+	ra, rb := keyRank(a), keyRank(b)
+	if rb == otherKeyRank {
+		return -defaultCompare(b, a)
+	}
+	switch {
+	case ra < rb:
+		return -1
+	case ra > rb:
+		return 1
+	}
+	return 0
+}
+
+//compareIntUint is the three way numeric comparison of a signed and an
+//unsigned integer.
+func compareIntUint(i int64, u uint64) int {
+	if i < 0 || uint64(i) < u {
+		return -1
+	}
+	if uint64(i) > u {
+		return 1
+	}
+	return 0
+}
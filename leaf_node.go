@@ -4,34 +4,36 @@ import (
 	"fmt"
 )
 
-type leafNodeS struct {
-	keys []BptKey
-	vals []interface{}
+type leafNodeS[K, V any] struct {
+	keys   []K
+	vals   []V
+	edit   *editT //the transient edit session that owns this leaf, if any
+	frozen bool   //set by freeze() when ASSERT is set; see freeze.go
 }
 
-func mkLeaf(order int) *leafNodeS {
+func mkLeaf[K, V any](order int) *leafNodeS[K, V] {
 	//set the capacity of keys and vals one slot to much
 	//so the leaf can reach the isToBig() condition and be split
-	var node = new(leafNodeS)
-	node.keys = make([]BptKey, 0, order)
-	node.vals = make([]interface{}, 0, order)
+	var node = new(leafNodeS[K, V])
+	node.keys = make([]K, 0, order)
+	node.vals = make([]V, 0, order)
 	return node
 }
 
-func (node *leafNodeS) copy() *leafNodeS {
-	copyNode := mkLeaf(node.order())
+func (node *leafNodeS[K, V]) copy() *leafNodeS[K, V] {
+	copyNode := mkLeaf[K, V](node.order())
 	copyNode.keys = append(copyNode.keys, node.keys...)
 	copyNode.vals = append(copyNode.vals, node.vals...)
 	return copyNode
 }
 
-func (node *leafNodeS) String() string {
+func (node *leafNodeS[K, V]) String() string {
 	s := ""
 	s += fmt.Sprintf("%p: LEAF: len(node.keys)=%d; cap(node.keys)=%d; len(node.vals)=%d; cap(node.vals)=%d;\n", node, len(node.keys), cap(node.keys), len(node.vals), cap(node.vals))
 	s += fmt.Sprintf("%p: keys = ", node)
 	keys := make([]string, 0, 2)
 	for _, key := range node.keys {
-		keys = append(keys, fmt.Sprintf("%q", key.String()))
+		keys = append(keys, fmt.Sprintf("%q", fmt.Sprint(key)))
 	}
 	s += fmt.Sprintf("%v\n", keys)
 
@@ -45,45 +47,57 @@ func (node *leafNodeS) String() string {
 	return s
 }
 
-func (l *leafNodeS) equals(rn nodeI) bool {
-	r := rn.(*leafNodeS) //blows up if casting doesn't work
-	return l == r        //pointers are equal
+func (l *leafNodeS[K, V]) equals(rn nodeI[K, V]) bool {
+	r := rn.(*leafNodeS[K, V]) //blows up if casting doesn't work
+	return l == r              //pointers are equal
 }
 
-//leaf.insert(key, val) returns true if a new key,val pair was inserted.
-//leaf.insert(key, val) returns false if the val for a existing key,val pair
-//was updated in place.
-func (leaf *leafNodeS) insert(key BptKey, val interface{}) bool {
-	var i int
-	for i = 0; i < len(leaf.keys); i++ {
-		switch {
-		case key.Equals(leaf.keys[i]):
-			leaf.vals[i] = val
-			return false //replaced not inserted
-		case key.LessThan(leaf.keys[i]):
-			leaf.keys = append(leaf.keys[:i+1], leaf.keys[i:]...)
-			leaf.vals = append(leaf.vals[:i+1], leaf.vals[i:]...)
-			leaf.keys[i] = key
-			leaf.vals[i] = val
-			return true
-		}
+//leaf.get(key, cmp) returns the val stored for key, and whether key was
+//found.
+func (leaf *leafNodeS[K, V]) get(key K, cmp compareFunc[K]) (V, bool) {
+	i, found := searchLeaf(leaf.keys, key, cmp)
+	if !found {
+		var zero V
+		return zero, false
+	}
+	return leaf.vals[i], true
+}
+
+//leaf.insert(key, val, cmp) returns the zero V, true if a new key,val pair
+//was inserted. leaf.insert(key, val, cmp) returns the old val, false if the
+//val for a existing key,val pair was updated in place.
+func (leaf *leafNodeS[K, V]) insert(key K, val V, cmp compareFunc[K]) (old V, added bool) {
+	if ASSERT {
+		assertMutable(leaf, "leafNodeS.insert")
+	}
+	i, found := searchLeaf(leaf.keys, key, cmp)
+	if found {
+		old = leaf.vals[i]
+		leaf.vals[i] = val
+		return old, false //replaced not inserted
 	}
 	if i == len(leaf.keys) {
 		leaf.keys = append(leaf.keys, key)
 		leaf.vals = append(leaf.vals, val)
+		return old, true
 	}
-	return true
+	leaf.keys = append(leaf.keys[:i+1], leaf.keys[i:]...)
+	leaf.vals = append(leaf.vals[:i+1], leaf.vals[i:]...)
+	leaf.keys[i] = key
+	leaf.vals[i] = val
+	return old, true
 }
 
-func (leaf *leafNodeS) remove(key BptKey) (val interface{}, removed bool) {
-	for i, k := range leaf.keys {
-		if key.Equals(k) {
-			val = leaf.vals[i]
-			leaf.keys = append(leaf.keys[:i], leaf.keys[i+1:]...)
-			leaf.vals = append(leaf.vals[:i], leaf.vals[i+1:]...)
-			removed = true
-			return
-		}
+func (leaf *leafNodeS[K, V]) remove(key K, cmp compareFunc[K]) (val V, removed bool) {
+	if ASSERT {
+		assertMutable(leaf, "leafNodeS.remove")
+	}
+	i, found := searchLeaf(leaf.keys, key, cmp)
+	if found {
+		val = leaf.vals[i]
+		leaf.keys = append(leaf.keys[:i], leaf.keys[i+1:]...)
+		leaf.vals = append(leaf.vals[:i], leaf.vals[i+1:]...)
+		removed = true
 	}
 	return
 }
@@ -94,20 +108,23 @@ func (leaf *leafNodeS) remove(key BptKey) (val interface{}, removed bool) {
 //that same page, then nodes/leafs must be allowed to get bigger than the
 //order allows and thus qualify for the SPLIT operation. That condition
 //should be TOBIG not FULL.
-func (n *leafNodeS) isToBig() bool {
+func (n *leafNodeS[K, V]) isToBig() bool {
 	return len(n.keys) == cap(n.keys)
 }
 
-func (n *leafNodeS) isToSmall() bool {
+func (n *leafNodeS[K, V]) isToSmall() bool {
 	return n.size() < n.halfFullSize()
 }
 
 //leafSplit must chop the receiving and overlarge(by one) leaf node in half.
 //Leaving the original node shrunk by half and returning the new right half
 //and the MIDDLE Key (of the orignial overlarge leaf node).
-func (lNode *leafNodeS) split() (*leafNodeS, BptKey) {
+func (lNode *leafNodeS[K, V]) split() (*leafNodeS[K, V], K) {
+	if ASSERT {
+		assertMutable(lNode, "leafNodeS.split")
+	}
 	order := lNode.order()
-	rLeaf := mkLeaf(order)
+	rLeaf := mkLeaf[K, V](order)
 
 	//leafSplit for ODD orders makes the right node the larger node.
 	//hence the MIDDLE KEY is rLeaf.keys[0], for ODD and EVEN orders.
@@ -123,46 +140,50 @@ func (lNode *leafNodeS) split() (*leafNodeS, BptKey) {
 	return rLeaf, rLeaf.keys[0]
 }
 
-func (rNode *leafNodeS) findPeerLeft(parent *interiorNodeS) (*leafNodeS, BptKey) {
-	var leftPeerLeaf *leafNodeS
-	var leftPeerKey BptKey
+func (rNode *leafNodeS[K, V]) findPeerLeft(parent *interiorNodeS[K, V]) (*leafNodeS[K, V], K) {
+	var leftPeerLeaf *leafNodeS[K, V]
+	var leftPeerKey K
 	var i int
 	for i = 0; i < len(parent.vals); i++ {
 		if rNode.equals(parent.vals[i]) {
 			if i == 0 {
 				//there is no left peer
-				return nil, nil
+				return nil, leftPeerKey
 			}
-			leftPeerLeaf = parent.vals[i-1].(*leafNodeS)
+			leftPeerLeaf = parent.vals[i-1].(*leafNodeS[K, V])
 			leftPeerKey = parent.keys[i-1]
 			return leftPeerLeaf, leftPeerKey
 		}
 	}
 	lgr.Panic("findPeerLeft: didn't find rNode(receiver) in parent")
-	return nil, nil
+	return nil, leftPeerKey
 }
 
-func (lNode *leafNodeS) findPeerRight(parent *interiorNodeS) (*leafNodeS, BptKey) {
-	var rightPeerLeaf *leafNodeS
-	var rightPeerKey BptKey
+func (lNode *leafNodeS[K, V]) findPeerRight(parent *interiorNodeS[K, V]) (*leafNodeS[K, V], K) {
+	var rightPeerLeaf *leafNodeS[K, V]
+	var rightPeerKey K
 	var i int
 	for i = 0; i < len(parent.vals); i++ {
 		if lNode.equals(parent.vals[i]) {
 			if i == len(parent.vals)-1 {
 				//there is no right peer
-				return nil, nil
+				return nil, rightPeerKey
 			}
-			rightPeerLeaf = parent.vals[i+1].(*leafNodeS)
+			rightPeerLeaf = parent.vals[i+1].(*leafNodeS[K, V])
 			rightPeerKey = parent.keys[i]
 			return rightPeerLeaf, rightPeerKey
 		}
 	}
 	lgr.Panic("findPeerRight: didn't find lNode(receiver) in parent")
-	return nil, nil
+	return nil, rightPeerKey
 }
 
 //Given left peer, steal its right most
-func (rLeaf *leafNodeS) stealLeft(lLeaf *leafNodeS) {
+func (rLeaf *leafNodeS[K, V]) stealLeft(lLeaf *leafNodeS[K, V]) {
+	if ASSERT {
+		assertMutable(rLeaf, "leafNodeS.stealLeft")
+		assertMutable(lLeaf, "leafNodeS.stealLeft")
+	}
 	stolenKey := lLeaf.keys[len(lLeaf.keys)-1]
 	stolenVal := lLeaf.vals[len(lLeaf.vals)-1]
 	//this preserves cap(lLeaf.keys) and cap(lLeaf.vals)
@@ -171,14 +192,18 @@ func (rLeaf *leafNodeS) stealLeft(lLeaf *leafNodeS) {
 
 	//unshift operation that preserves cap(rLeaf.keys)
 	rLeaf.keys = append(rLeaf.keys[:0],
-		append([]BptKey{stolenKey}, rLeaf.keys...)...)
+		append([]K{stolenKey}, rLeaf.keys...)...)
 	//unshift operation that preserves cap(rLeaf.vals)
 	rLeaf.vals = append(rLeaf.vals[:0],
-		append([]interface{}{stolenVal}, rLeaf.vals...)...)
+		append([]V{stolenVal}, rLeaf.vals...)...)
 }
 
 //Given right peer, steal its left most entry.
-func (lLeaf *leafNodeS) stealRight(rLeaf *leafNodeS) {
+func (lLeaf *leafNodeS[K, V]) stealRight(rLeaf *leafNodeS[K, V]) {
+	if ASSERT {
+		assertMutable(lLeaf, "leafNodeS.stealRight")
+		assertMutable(rLeaf, "leafNodeS.stealRight")
+	}
 	stolenKey := rLeaf.keys[0]
 	stolenVal := rLeaf.vals[0]
 
@@ -190,30 +215,39 @@ func (lLeaf *leafNodeS) stealRight(rLeaf *leafNodeS) {
 	lLeaf.vals = append(lLeaf.vals, stolenVal)
 }
 
-func (lLeaf *leafNodeS) mergeRight(rLeaf *leafNodeS) {
+func (lLeaf *leafNodeS[K, V]) mergeRight(rLeaf *leafNodeS[K, V]) {
+	if ASSERT {
+		assertMutable(lLeaf, "leafNodeS.mergeRight")
+	}
 	lLeaf.keys = append(lLeaf.keys, rLeaf.keys...)
 	lLeaf.vals = append(lLeaf.vals, rLeaf.vals...)
 }
 
-func (n *leafNodeS) isLeaf() bool {
+func (n *leafNodeS[K, V]) isLeaf() bool {
 	return true
 	//return cap(n.keys) == cap(n.vals)
 }
 
-func (leaf *leafNodeS) findLeftMostKey() BptKey {
+func (leaf *leafNodeS[K, V]) findLeftMostKey() K {
 	return leaf.keys[0]
 }
 
-func (n *leafNodeS) order() int {
+func (n *leafNodeS[K, V]) order() int {
 	//in both leaf and interior nodes; see mkLeaf && mkNode
 	return cap(n.keys)
 }
 
-func (n *leafNodeS) size() int {
+func (n *leafNodeS[K, V]) size() int {
+	return len(n.vals)
+}
+
+//count returns the number of entries in the leaf; for leaves this is the
+//same as size().
+func (n *leafNodeS[K, V]) count() int {
 	return len(n.vals)
 }
 
-func (n *leafNodeS) halfFullSize() int {
+func (n *leafNodeS[K, V]) halfFullSize() int {
 	// int(math.Ceil(float64(n)/2)) == (n+1)/2 (in integer math)
 
 	//For leaf nodes halfFullSize == math.Ceil( (float64(order)-1)/2 )
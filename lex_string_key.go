@@ -0,0 +1,36 @@
+package bptree
+
+import (
+	"strings"
+)
+
+//LexStringKey is a BptKey implementation for strings that orders them the
+//way Go's string comparison does, byte by byte, so "aa" < "b". Unlike
+//StringKey, every key sharing a prefix sorts together. Construction is
+//simply LexStringKey(mystring).
+type LexStringKey string
+
+//Compare returns -1, 0 or 1 as the receiver is less than, equal to or
+//greater than the argument. Keys of other types are ordered by type.
+func (k0 LexStringKey) Compare(K1 BptKey) int {
+	k1, ok := K1.(LexStringKey)
+	if !ok {
+		return compareRanks(k0, K1)
+	}
+	return strings.Compare(string(k0), string(k1))
+}
+
+//Equals is Compare(K1) == 0.
+func (k0 LexStringKey) Equals(K1 BptKey) bool {
+	return k0.Compare(K1) == 0
+}
+
+//LessThan is Compare(K1) < 0.
+func (k0 LexStringKey) LessThan(K1 BptKey) bool {
+	return k0.Compare(K1) < 0
+}
+
+//String Trivial.
+func (k LexStringKey) String() string {
+	return string(k)
+}
@@ -0,0 +1,101 @@
+package bptree
+
+//MergeConflict describes a key that both sides of a Merge() changed, in
+//different ways. The In* fields say whether the key is present in each
+//version; the matching value is nil when it is not.
+type MergeConflict struct {
+	Key                     BptKey
+	Base, Left, Right       interface{}
+	InBase, InLeft, InRight bool
+}
+
+//MergeResolver decides the outcome of a MergeConflict. It returns the value
+//the merged tree should have for the key, or keep=false to leave the key out
+//of the merged tree.
+type MergeResolver func(c MergeConflict) (val interface{}, keep bool)
+
+//Merge(base, left, right, resolve) does a three-way merge of left and
+//right, two versions of a tree that were both derived from base. Every
+//change that only one side made to base is kept. When both sides changed the
+//same key in the same way (put equal values, or both deleted it) that change
+//is kept too; otherwise resolve is called to decide. A nil resolve lets the
+//left side win every conflict.
+//
+//The changes each side made are found with Diff(), which skips the
+//subtrees a side still shares with base. The side with more changes is used
+//as the starting point, and the other side's changes are applied to it in a
+//single Apply() batch, so the merged tree shares every node of the starting
+//side that the batch does not touch, including the nodes both sides still
+//share with base.
+func Merge(base, left, right BpTree, resolve MergeResolver) BpTree {
+	if resolve == nil {
+		resolve = func(c MergeConflict) (interface{}, bool) {
+			return c.Left, c.InLeft
+		}
+	}
+
+	cmp := base.(*tree).cmp
+	ldiffs := Diff(base, left)
+	rdiffs := Diff(base, right)
+
+	//start from the side with more changes and apply the other one's
+	into, fromDiffs, intoDiffs := left, rdiffs, ldiffs
+	fromLeft := false
+	if len(rdiffs) > len(ldiffs) {
+		into, fromDiffs, intoDiffs = right, ldiffs, rdiffs
+		fromLeft = true
+	}
+
+	var ops []BatchOp
+	var i, j int
+	for i < len(fromDiffs) {
+		fd := fromDiffs[i]
+		for j < len(intoDiffs) && cmp(intoDiffs[j].Key, fd.Key) < 0 {
+			j++
+		}
+		if j == len(intoDiffs) || cmp(intoDiffs[j].Key, fd.Key) != 0 {
+			//only the from side changed this key
+			ops = append(ops, diffOp(fd))
+			i++
+			continue
+		}
+
+		id := intoDiffs[j]
+		i++
+		j++
+		if fd.Kind == DiffRemoved && id.Kind == DiffRemoved {
+			continue
+		}
+		if fd.Kind != DiffRemoved && id.Kind != DiffRemoved &&
+			defaultValEquals(fd.NewVal, id.NewVal) {
+			continue
+		}
+
+		c := MergeConflict{Key: fd.Key}
+		c.Base, c.InBase = fd.OldVal, fd.Kind != DiffAdded
+		ld, rd := id, fd
+		if fromLeft {
+			ld, rd = fd, id
+		}
+		c.Left, c.InLeft = ld.NewVal, ld.Kind != DiffRemoved
+		c.Right, c.InRight = rd.NewVal, rd.Kind != DiffRemoved
+
+		val, keep := resolve(c)
+		if keep {
+			ops = append(ops, BatchOp{Key: fd.Key, Val: val})
+		} else {
+			ops = append(ops, BatchOp{Del: true, Key: fd.Key})
+		}
+	}
+
+	merged, _ := into.Apply(ops)
+	return merged
+}
+
+//diffOp returns the BatchOp that makes the same change as d.
+func diffOp(d DiffEntry) BatchOp {
+	if d.Kind == DiffRemoved {
+		return BatchOp{Del: true, Key: d.Key}
+	}
+	return BatchOp{Key: d.Key, Val: d.NewVal}
+}
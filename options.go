@@ -0,0 +1,108 @@
+package bptree
+
+//Option configures a B+Tree made by NewBpTree() or BuildFromSorted(). Every
+//version derived from the tree, by Put(), Del() or any other operation,
+//keeps the same options.
+type Option func(*tree)
+
+//WithValEquals(eq) makes Put() and the other operations that store a value
+//check whether the key already holds a value equal to the new one,
+//according to eq. If it does, nothing is copied and the receiver itself is
+//returned, which SameVersion() can detect. A nil eq compares values the same
+//way BpTree.Equals() does.
+//
+//Without WithValEquals() every Put() of a key that is already present makes
+//a new version, even if the value is unchanged. Replace(), CompareAndSwap()
+//and Update() always compare the new value to the old one, with eq or else
+//the same way BpTree.Equals() does, and return the receiver when they are
+//equal.
+func WithValEquals(eq func(a, b interface{}) bool) Option {
+	if eq == nil {
+		eq = defaultValEquals
+	}
+	return func(t *tree) {
+		t.valEq = eq
+	}
+}
+
+//WithCompare(cmp) orders the keys of the tree with cmp, which must return a
+//negative number if a < b, zero if a == b, and a positive number if a > b.
+//Every key comparison the tree makes, in searches, inserts, deletes, range
+//scans, Diff() and the set operations, is then a single call to cmp instead
+//of a call to the keys' Equals() followed by one to LessThan().
+//
+//Without WithCompare() keys are ordered by their own Compare(BptKey) int
+//method, if they have one, or else by their Equals() and LessThan() methods.
+//Trees combined by Join(), Merge() and the set operations must use the same
+//ordering.
+func WithCompare(cmp func(a, b BptKey) int) Option {
+	if cmp == nil {
+		cmp = defaultCompare
+	}
+	return func(t *tree) {
+		t.cmp = cmp
+	}
+}
+
+//compareFunc is the three way key comparison used throughout the tree.
+type compareFunc[K any] func(a, b K) int
+
+//keyComparer is implemented by keys that can compare themselves to another
+//key in a single call. It is the same contract as WithCompare()'s cmp.
+type keyComparer interface {
+	Compare(BptKey) int
+}
+
+//defaultCompare orders keys by their Compare() method, if they have one, or
+//else by their Equals() and LessThan() methods.
+func defaultCompare(a, b BptKey) int {
+	if c, ok := a.(keyComparer); ok {
+		return c.Compare(b)
+	}
+	if a.Equals(b) {
+		return 0
+	}
+	if a.LessThan(b) {
+		return -1
+	}
+	return 1
+}
+
+//SameVersion(a, b) returns true if a and b are the same version of a tree,
+//for instance when a Put() into a tree made WithValEquals() did not change
+//anything. Unlike Equals(), it does not look at the contents of the trees.
+func SameVersion(a, b BpTree) bool {
+	return a.(*tree) == b.(*tree)
+}
+
+//mkEmpty returns an empty tree with the same order and options as t.
+func (t *tree) mkEmpty() *tree {
+	return &tree{t.empty()}
+}
+
+//empty is mkEmpty() for a Tree, returned by value like mkTree().
+func (t *Tree[K, V]) empty() Tree[K, V] {
+	nt := mkTree[K, V](t.order, t.cmp)
+	nt.valEq = t.valEq
+	return nt
+}
+
+//valEquals compares two values with the function given to WithValEquals(),
+//or with defaultValEquals if there is none.
+func (t *Tree[K, V]) valEquals(a, b V) bool {
+	if t.valEq == nil {
+		return defaultValEquals(a, b)
+	}
+	return t.valEq(a, b)
+}
+
+//isNoopPut returns true if storing val for key, whose leaf is leaf, would
+//not change t: t was made WithValEquals(), and key is already stored with a
+//value equal to val.
+func (t *Tree[K, V]) isNoopPut(leaf *leafNodeS[K, V], key K, val V) bool {
+	if t.valEq == nil {
+		return false
+	}
+	old, found := leaf.get(key, t.cmp)
+	return found && t.valEq(old, val)
+}
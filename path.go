@@ -5,15 +5,15 @@ import (
 	"strings"
 )
 
-type pathT []*interiorNodeS
+type pathT[K, V any] []*interiorNodeS[K, V]
 
 //Constructs an empty pathT object.
-func newPathT() pathT {
-	return pathT(make([]*interiorNodeS, 0, 2))
+func newPathT[K, V any]() pathT[K, V] {
+	return pathT[K, V](make([]*interiorNodeS[K, V], 0, 2))
 }
 
 //path.peek() return the last entry inter inserted with path.push(...).
-func (path *pathT) peek() *interiorNodeS {
+func (path *pathT[K, V]) peek() *interiorNodeS[K, V] {
 	if len(*path) == 0 {
 		return nil
 	}
@@ -21,7 +21,7 @@ func (path *pathT) peek() *interiorNodeS {
 }
 
 //path.pop() returns & remmoves the last entry inserted with path.push(...).
-func (path *pathT) pop() *interiorNodeS {
+func (path *pathT[K, V]) pop() *interiorNodeS[K, V] {
 	if len(*path) == 0 {
 		//should I do this or let the runtime panic on index out of range
 		return nil
@@ -34,22 +34,22 @@ func (path *pathT) pop() *interiorNodeS {
 
 //Put a new *interiorNodeS in the path object.
 //You should never push nil, but we are not checking to prevent this.
-func (path *pathT) push(node *interiorNodeS) {
+func (path *pathT[K, V]) push(node *interiorNodeS[K, V]) {
 	//_ = ASSERT && Assert(node != nil, "pathT.push(nil) not allowed")
 	*path = append(*path, node)
 }
 
 //path.isEmpty() returns true if there are no entries in the path object,
 //otherwise it returns false.
-func (path *pathT) isEmpty() bool {
+func (path *pathT[K, V]) isEmpty() bool {
 	return len(*path) == 0
 }
 
 //Convert path to a string representation. This is only good for debug messages.
 //It is not a string format to convert back from.
-func (path *pathT) String() string {
+func (path *pathT[K, V]) String() string {
 	s := "["
-	pvs := []*interiorNodeS(*path)
+	pvs := []*interiorNodeS[K, V](*path)
 	strs := make([]string, 0, 2)
 	for _, pv := range pvs {
 		strs = append(strs, fmt.Sprintf("%p", pv))
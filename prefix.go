@@ -0,0 +1,102 @@
+package bptree
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+//PrefixKey is implemented by key types whose ordering keeps every key that
+//starts with a given prefix together, right after the prefix itself, which
+//is what PrefixScan() relies on. LexStringKey, BytesKey and TupleKey are
+//PrefixKeys; StringKey is not, since it orders shorter strings first.
+type PrefixKey interface {
+	BptKey
+	//HasPrefix returns true if the receiver starts with prefix.
+	HasPrefix(prefix BptKey) bool
+}
+
+//PrefixScan(prefix, limit) returns a BptIter over the entries whose keys
+//start with prefix, in ascending order, stopping after limit entries if
+//limit > 0. For example the first 20 completions of "abc" are
+//
+//    t.PrefixScan(LexStringKey("abc"), 20)
+//
+//The scan seeks straight to prefix and stops at the first key that does not
+//start with it, so it costs O(log n + k). An error is returned if prefix is
+//not a PrefixKey. The tree must also be ordered by the keys' own ordering,
+//not by a WithCompare() function that orders them differently.
+func (t *tree) PrefixScan(prefix BptKey, limit int) (BptIter, error) {
+	if _, ok := prefix.(StringKey); ok {
+		return nil, fmt.Errorf("PrefixScan: StringKey orders shorter keys first, so keys with the prefix %q are not contiguous; use LexStringKey", prefix)
+	}
+	if _, ok := prefix.(PrefixKey); !ok {
+		return nil, fmt.Errorf("PrefixScan: prefix %q of type %T is not a PrefixKey", prefix, prefix)
+	}
+	it := t.Range(prefix, true, nil, false)
+	return &prefixIterS{it: it, prefix: prefix, limit: limit}, nil
+}
+
+//prefixIterS stops a range iterator at the first key without the prefix.
+type prefixIterS struct {
+	it     BptIter
+	prefix BptKey
+	limit  int //0 means no limit
+	n      int //number of entries returned so far
+	done   bool
+}
+
+//Next advances the iterator to the next entry with the prefix. It returns
+//false when there are no more such entries, or the limit was reached.
+func (p *prefixIterS) Next() bool {
+	if p.done || (p.limit > 0 && p.n == p.limit) || !p.it.Next() {
+		p.done = true
+		return false
+	}
+	k, ok := p.it.Key().(PrefixKey)
+	if !ok || !k.HasPrefix(p.prefix) {
+		p.done = true
+		return false
+	}
+	p.n++
+	return true
+}
+
+//Key returns the key of the current entry.
+func (p *prefixIterS) Key() BptKey {
+	if p.done {
+		return nil
+	}
+	return p.it.Key()
+}
+
+//Val returns the value of the current entry.
+func (p *prefixIterS) Val() interface{} {
+	if p.done {
+		return nil
+	}
+	return p.it.Val()
+}
+
+//HasPrefix returns true if prefix is a LexStringKey the receiver starts
+//with.
+func (k LexStringKey) HasPrefix(prefix BptKey) bool {
+	p, ok := prefix.(LexStringKey)
+	return ok && strings.HasPrefix(string(k), string(p))
+}
+
+//HasPrefix returns true if prefix is a BytesKey the receiver starts with.
+func (k BytesKey) HasPrefix(prefix BptKey) bool {
+	p, ok := prefix.(BytesKey)
+	return ok && bytes.HasPrefix(k, p)
+}
+
+//HasPrefix returns true if prefix is a TupleKey whose components are the
+//first components of the receiver.
+func (k TupleKey) HasPrefix(prefix BptKey) bool {
+	p, ok := prefix.(TupleKey)
+	if !ok || len(p) > len(k) {
+		return false
+	}
+	return k[:len(p)].Equals(p)
+}
@@ -0,0 +1,43 @@
+package bptree
+
+//Both searches are binary searches that make a single three way comparison
+//per probe. The keys of a node are always sorted, so at order 64 a search
+//makes about 6 comparisons where a linear scan would average 32, each of
+//which used to cost an Equals() and a LessThan() call.
+
+//searchLeaf returns the index of the first of keys that is not less than
+//key, which is where key is or would be inserted in a leaf, and whether
+//the key at that index is equal to key.
+func searchLeaf[K any](keys []K, key K, cmp compareFunc[K]) (int, bool) {
+	lo, hi := 0, len(keys)
+	for lo < hi {
+		m := int(uint(lo+hi) >> 1)
+		c := cmp(keys[m], key)
+		switch {
+		case c == 0:
+			return m, true
+		case c < 0:
+			lo = m + 1
+		default:
+			hi = m
+		}
+	}
+	return lo, false
+}
+
+//searchNode returns the number of keys that are less than or equal to key.
+//For the keys of an interior node that is the index of the child that key
+//belongs under, as node.keys[i] is greater than every key under
+//node.vals[i] and less than or equal to every key under node.vals[i+1].
+func searchNode[K any](keys []K, key K, cmp compareFunc[K]) int {
+	lo, hi := 0, len(keys)
+	for lo < hi {
+		m := int(uint(lo+hi) >> 1)
+		if cmp(key, keys[m]) < 0 {
+			hi = m
+		} else {
+			lo = m + 1
+		}
+	}
+	return lo
+}
@@ -0,0 +1,146 @@
+package bptree
+
+//Combiner returns the value to keep for a key that is in both trees of a
+//Union() or Intersect(); aVal is the value from the first tree and bVal the
+//value from the second.
+type Combiner func(key BptKey, aVal, bVal interface{}) interface{}
+
+//setOpFill is the fill factor of the leaves and interior nodes packed by the
+//set operations.
+const setOpFill = 0.75
+
+//Union(a, b, combine) returns a tree with every entry of a and of b. Keys in
+//both trees get the value combine returns, or a's value if combine is nil.
+//
+//a and b must have the same order. The result is built in a single merge of
+//the two trees in key order, so it takes O(n+m) time rather than the
+//O(m log(n+m)) of Put()ing every entry of b into a. Whole leaves that lie
+//between the keys of the other tree are reused without being copied, and
+//when combine is nil the leaves of a subtree shared by a and b are reused
+//without comparing their keys.
+func Union(a, b BpTree, combine Combiner) BpTree {
+	checkSetOrders("Union", a, b)
+	if a.IsEmpty() {
+		return b
+	}
+	if b.IsEmpty() || (combine == nil && a.(*tree).root == b.(*tree).root) {
+		return a
+	}
+	return setOp(a.(*tree), b.(*tree), true, true, true, combine)
+}
+
+//Intersect(a, b, combine) returns a tree with the keys that are in both a
+//and b. Each key gets the value combine returns, or a's value if combine is
+//nil. The order and cost requirements are the same as for Union().
+func Intersect(a, b BpTree, combine Combiner) BpTree {
+	checkSetOrders("Intersect", a, b)
+	if a.IsEmpty() {
+		return a
+	}
+	if b.IsEmpty() {
+		return b
+	}
+	if combine == nil && a.(*tree).root == b.(*tree).root {
+		return a
+	}
+	return setOp(a.(*tree), b.(*tree), true, false, false, combine)
+}
+
+//Subtract(a, b) returns a tree with the entries of a whose keys are not in
+//b. The order and cost requirements are the same as for Union(), except
+//that a subtree shared by a and b is dropped without visiting its leaves.
+func Subtract(a, b BpTree) BpTree {
+	checkSetOrders("Subtract", a, b)
+	if a.IsEmpty() || b.IsEmpty() {
+		return a
+	}
+	if a.(*tree).root == b.(*tree).root {
+		return a.(*tree).mkEmpty()
+	}
+	return setOp(a.(*tree), b.(*tree), false, true, false, nil)
+}
+
+func checkSetOrders(name string, a, b BpTree) {
+	if a.Order() != b.Order() {
+		lgr.Panicf("%s: a.Order()=%d != b.Order()=%d", name, a.Order(), b.Order())
+	}
+}
+
+//setOp merges at and bt in key order into a new tree, keeping the keys in
+//both trees if both is true, and the keys only in at if onlyA is true, and
+//the keys only in bt if onlyB is true.
+func setOp(at, bt *tree, both, onlyA, onlyB bool, combine Combiner) BpTree {
+	nt := at.mkEmpty()
+	bld := mkBuilder(&nt.Tree, setOpFill)
+
+	ai := at.iter()
+	bi := bt.iter()
+	aok, bok := ai.Next(), bi.Next()
+	for (aok && (bok || onlyA)) || (bok && onlyB) {
+		if aok && bok && combine == nil {
+			if alvl, blvl := sharedStart(ai, bi); alvl >= 0 {
+				if both {
+					forEachLeaf(ai.nodeAt(alvl), bld.mustAddLeaf)
+				}
+				ai.skip(alvl)
+				bi.skip(blvl)
+				aok, bok = ai.valid(), bi.valid()
+				continue
+			}
+		}
+
+		switch {
+		case !bok:
+			aok = setAdvance(bld, ai, nil, onlyA)
+		case !aok:
+			bok = setAdvance(bld, bi, nil, onlyB)
+		case at.cmp(ai.Key(), bi.Key()) < 0:
+			aok = setAdvance(bld, ai, bi.Key(), onlyA)
+		case at.cmp(bi.Key(), ai.Key()) < 0:
+			bok = setAdvance(bld, bi, ai.Key(), onlyB)
+		default:
+			if both {
+				val := ai.Val()
+				if combine != nil {
+					val = combine(ai.Key(), ai.Val(), bi.Val())
+				}
+				bld.mustAdd(ai.Key(), val)
+			}
+			aok, bok = ai.Next(), bi.Next()
+		}
+	}
+
+	bld.finish()
+	return nt.freeze()
+}
+
+//setAdvance moves it past its current entry, adding the entry to bld if keep
+//is true. When it is at the start of a leaf whose keys are all less than
+//bound, or bound is nil, it is moved past the whole leaf at once.
+func setAdvance(bld *builderT[BptKey, interface{}], it *iterS[BptKey, interface{}], bound BptKey, keep bool) bool {
+	leaf := it.leaf
+	if it.idx == 0 && (bound == nil || bld.t.cmp(leaf.keys[len(leaf.keys)-1], bound) < 0) {
+		if keep {
+			bld.mustAddLeaf(leaf)
+		}
+		it.skip(len(it.path))
+		return it.valid()
+	}
+
+	if keep {
+		bld.mustAdd(it.Key(), it.Val())
+	}
+	return it.Next()
+}
+
+//forEachLeaf calls fn for every leaf under node, from left to right.
+func forEachLeaf[K, V any](node nodeI[K, V], fn func(*leafNodeS[K, V])) {
+	switch n := node.(type) {
+	case *leafNodeS[K, V]:
+		fn(n)
+	case *interiorNodeS[K, V]:
+		for _, kid := range n.vals {
+			forEachLeaf(kid, fn)
+		}
+	}
+}
@@ -0,0 +1,270 @@
+package bptree
+
+//SplitAt(key) returns two trees: one with every entry of t whose key is less
+//than key, and one with every entry whose key is greater than or equal to
+//key. t is left unchanged.
+//
+//Only the nodes on the path to key are rebuilt; every subtree to either
+//side of the path is shared with t, so SplitAt() takes O(log n) time.
+func (t *tree) SplitAt(key BptKey) (BpTree, BpTree) {
+	if t.IsEmpty() {
+		return t, t
+	}
+	if minKey, _, _ := t.Min(); t.cmp(minKey, key) >= 0 {
+		return t.mkEmpty(), t
+	}
+	if maxKey, _, _ := t.Max(); t.cmp(maxKey, key) < 0 {
+		return t, t.mkEmpty()
+	}
+
+	l, lh, r, rh := splitNode(t.root, t.depth, key, t.cmp)
+	return t.withRoot(l, lh).freeze(), t.withRoot(r, rh).freeze()
+}
+
+//Join(left, right) returns a tree with every entry of left and of right.
+//left and right must have the same order, and every key in left must be
+//less than every key in right.
+//
+//The shorter tree is hung off the side of the taller one at the height
+//where they meet, and only the nodes on the taller tree's spine down to
+//that height are rebuilt, so Join() takes O(log n) time.
+func Join(left, right BpTree) BpTree {
+	lt, rt := left.(*tree), right.(*tree)
+	if lt.order != rt.order {
+		lgr.Panicf("Join: left.Order()=%d != right.Order()=%d", lt.order, rt.order)
+	}
+	if lt.IsEmpty() {
+		return right
+	}
+	if rt.IsEmpty() {
+		return left
+	}
+	lmax, _, _ := lt.Max()
+	rmin, _, _ := rt.Min()
+	if lt.cmp(lmax, rmin) >= 0 {
+		lgr.Panicf("Join: greatest key in left %q is not less than least key in right %q", lmax, rmin)
+	}
+
+	root, depth := joinRoots(lt.root, lt.depth, rt.root, rt.depth)
+	return lt.withRoot(root, depth).freeze()
+}
+
+//DelRange(lo, hi) returns a tree without the entries of t whose keys are
+//greater than or equal to lo and less than hi, and the number of entries
+//removed. A nil lo or hi leaves that end of the range unbounded, so
+//DelRange(nil, cutoff) truncates every key below cutoff. If nothing is
+//removed t itself is returned.
+//
+//The range is cut out with two SplitAt()s and the two ends are put back
+//together with a Join(), so the subtrees inside the range are dropped
+//without being visited, and only the nodes on the paths to lo and hi are
+//rebuilt.
+func (t *tree) DelRange(lo, hi BptKey) (BpTree, int) {
+	if lo != nil && hi != nil && t.cmp(lo, hi) >= 0 {
+		return t, 0
+	}
+
+	var left, mid, right BpTree = t.mkEmpty(), t, t.mkEmpty()
+	if lo != nil {
+		left, mid = mid.SplitAt(lo)
+	}
+	if hi != nil {
+		mid, right = mid.SplitAt(hi)
+	}
+
+	removed := mid.NumberOfEntries()
+	if removed == 0 {
+		return t, 0
+	}
+	return Join(left, right), removed
+}
+
+//withRoot returns a tree with the order and options of t whose root is
+//root, at height depth, or an empty tree if root is nil.
+func (t *tree) withRoot(root nodeI[BptKey, interface{}], depth int) *tree {
+	nt := t.mkEmpty()
+	if root != nil {
+		nt.root = root
+		nt.depth = depth
+		nt.numEnts = root.count()
+	}
+	return nt
+}
+
+//splitNode splits the subtree rooted at node, of height h, into the roots
+//and heights of a subtree with the keys less than key and a subtree with the
+//rest. A nil root stands for an empty subtree.
+func splitNode[K, V any](node nodeI[K, V], h int, key K, cmp compareFunc[K]) (nodeI[K, V], int, nodeI[K, V], int) {
+	if node.isLeaf() {
+		leaf := node.(*leafNodeS[K, V])
+		i, _ := searchLeaf(leaf.keys, key, cmp)
+		switch i {
+		case 0:
+			return nil, 0, leaf, 0
+		case len(leaf.keys):
+			return leaf, 0, nil, 0
+		}
+		l := mkLeaf[K, V](leaf.order())
+		l.keys = append(l.keys, leaf.keys[:i]...)
+		l.vals = append(l.vals, leaf.vals[:i]...)
+		r := mkLeaf[K, V](leaf.order())
+		r.keys = append(r.keys, leaf.keys[i:]...)
+		r.vals = append(r.vals, leaf.vals[i:]...)
+		return l, 0, r, 0
+	}
+
+	n := node.(*interiorNodeS[K, V])
+	i := searchNode(n.keys, key, cmp)
+
+	kl, klh, kr, krh := splitNode(n.vals[i], h-1, key, cmp)
+	ll, llh := n.slice(0, i, h)
+	rr, rrh := n.slice(i+1, len(n.vals), h)
+
+	l, lh := joinRoots(ll, llh, kl, klh)
+	r, rh := joinRoots(kr, krh, rr, rrh)
+	return l, lh, r, rh
+}
+
+//slice returns the root and height of a subtree holding the children
+//vals[lo:hi] of node, which is at height h. It is nil for no children, the
+//child itself for one child, and a new node otherwise.
+func (node *interiorNodeS[K, V]) slice(lo, hi, h int) (nodeI[K, V], int) {
+	switch hi - lo {
+	case 0:
+		return nil, 0
+	case 1:
+		return node.vals[lo], h - 1
+	}
+	n := mkNode[K, V](node.order())
+	n.keys = append(n.keys, node.keys[lo:hi-1]...)
+	n.vals = append(n.vals, node.vals[lo:hi]...)
+	n.cnts = append(n.cnts, node.cnts[lo:hi]...)
+	return n, h
+}
+
+//joinRoots returns the root and height of a subtree with the entries of the
+//subtree rooted at l, of height lh, followed by those of the subtree rooted
+//at r, of height rh. Either root may be nil, for an empty subtree, and
+//either may be less than half full, as roots may be.
+func joinRoots[K, V any](l nodeI[K, V], lh int, r nodeI[K, V], rh int) (nodeI[K, V], int) {
+	switch {
+	case l == nil:
+		return r, rh
+	case r == nil:
+		return l, lh
+	case lh > rh:
+		return joinSpine(l, lh, r, rh, true)
+	case lh < rh:
+		return joinSpine(r, rh, l, lh, false)
+	}
+
+	ns := joinNodes(l, r)
+	if len(ns) == 1 {
+		return ns[0], lh
+	}
+	return mkParent(ns[0], ns[1].findLeftMostKey(), ns[1]), lh + 1
+}
+
+//joinSpine hangs the subtree rooted at short, of height sh, off the right
+//side of the taller subtree rooted at tall, of height th, if right is true,
+//or off its left side otherwise. Only the nodes on that side of tall, down
+//to height sh+1, are copied.
+func joinSpine[K, V any](tall nodeI[K, V], th int, short nodeI[K, V], sh int, right bool) (nodeI[K, V], int) {
+	path := make([]*interiorNodeS[K, V], 0, th-sh)
+	idxs := make([]int, 0, th-sh)
+	node := tall.(*interiorNodeS[K, V]).copy()
+	for h := th; ; h-- {
+		var i int
+		if right {
+			i = len(node.vals) - 1
+		}
+		path = append(path, node)
+		idxs = append(idxs, i)
+		if h == sh+1 {
+			break
+		}
+		kid := node.vals[i].(*interiorNodeS[K, V]).copy()
+		node.setChild(i, kid)
+		node = kid
+	}
+
+	//short may be less than half full, so it is joined with the outermost
+	//child rather than just being added beside it
+	i := idxs[len(idxs)-1]
+	var ns []nodeI[K, V]
+	if right {
+		ns = joinNodes(node.vals[i], short)
+	} else {
+		ns = joinNodes(short, node.vals[i])
+	}
+	node.setChild(i, ns[0])
+
+	var extra nodeI[K, V]
+	var extraKey K
+	if len(ns) == 2 {
+		extra, extraKey = ns[1], ns[1].findLeftMostKey()
+	}
+	for lvl := len(path) - 1; lvl >= 0; lvl-- {
+		node := path[lvl]
+		if lvl < len(path)-1 {
+			node.setChild(idxs[lvl], path[lvl+1])
+		}
+		if extra == nil {
+			continue
+		}
+		//extra goes just after the spine child
+		node.insertAt(idxs[lvl], extraKey, extra)
+		extra = nil
+		if node.isToBig() {
+			extra, extraKey = node.split()
+		}
+	}
+
+	if extra != nil {
+		return mkParent(path[0], extraKey, extra), th + 1
+	}
+	return path[0], th
+}
+
+//joinNodes returns the entries of l followed by those of r, two nodes of
+//the same height, as either one new node or two new nodes that are both at
+//least half full. One of l and r may be less than half full.
+func joinNodes[K, V any](l, r nodeI[K, V]) []nodeI[K, V] {
+	if l.isLeaf() {
+		ll, rl := l.(*leafNodeS[K, V]).copy(), r.(*leafNodeS[K, V]).copy()
+		if ll.size()+rl.size() < ll.order() {
+			ll.mergeRight(rl)
+			return []nodeI[K, V]{ll}
+		}
+		for ll.isToSmall() {
+			ll.stealRight(rl)
+		}
+		for rl.isToSmall() {
+			rl.stealLeft(ll)
+		}
+		return []nodeI[K, V]{ll, rl}
+	}
+
+	ln, rn := l.(*interiorNodeS[K, V]).copy(), r.(*interiorNodeS[K, V]).copy()
+	if ln.size()+rn.size() <= ln.order() {
+		ln.mergeRight(rn)
+		return []nodeI[K, V]{ln}
+	}
+	for ln.isToSmall() {
+		ln.stealRight(rn)
+	}
+	for rn.isToSmall() {
+		rn.stealLeft(ln)
+	}
+	return []nodeI[K, V]{ln, rn}
+}
+
+//mkParent returns a new interior node with the two children l and r, where
+//k is the least key in r.
+func mkParent[K, V any](l nodeI[K, V], k K, r nodeI[K, V]) *interiorNodeS[K, V] {
+	node := mkNode[K, V](l.order())
+	node.keys = append(node.keys, k)
+	node.vals = append(node.vals, l, r)
+	node.cnts = append(node.cnts, l.count(), r.count())
+	return node
+}
@@ -1,16 +1,21 @@
 package bptree
 
+import (
+	"strings"
+)
+
 //StringKey is a useful BptKey implementation for strings; construction is
-//simply StringKey(mystring)
+//simply StringKey(mystring). It orders shorter strings before longer ones,
+//see LessThan; LexStringKey orders strings the usual way.
 type StringKey string
 
 //Equals first checks that the argument passed in can be cast to StringKey.
 //Then it checks if these two arguments; the reciever and the single argument.
+//Keys of other types are compared by Compare.
 func (k0 StringKey) Equals(K1 BptKey) bool {
 	k1, ok := K1.(StringKey)
 	if !ok {
-		lgr.Printf("incompatable BptKey = %v\n", K1)
-		return false
+		return k0.Compare(K1) == 0
 	}
 	return string(k0) == string(k1)
 }
@@ -21,11 +26,12 @@ func (k0 StringKey) Equals(K1 BptKey) bool {
 // StringKey LessThan Rules:
 // 0 - shorter is less than longer
 // 1 - same sizes are compared as strings
+//
+//Keys of other types are compared by Compare.
 func (k0 StringKey) LessThan(K1 BptKey) bool {
 	k1, ok := K1.(StringKey)
 	if !ok {
-		lgr.Printf("incompatable BptKey = %v\n", K1)
-		return false
+		return k0.Compare(K1) < 0
 	}
 	if len(k0) < len(k1) {
 		return true
@@ -37,6 +43,23 @@ func (k0 StringKey) LessThan(K1 BptKey) bool {
 	return string(k0) < string(k1)
 }
 
+//Compare returns -1, 0 or 1 as the receiver is less than, equal to or
+//greater than the argument by the LessThan rules. Keys of other types are
+//ordered by type.
+func (k0 StringKey) Compare(K1 BptKey) int {
+	k1, ok := K1.(StringKey)
+	if !ok {
+		return compareRanks(k0, K1)
+	}
+	switch {
+	case len(k0) < len(k1):
+		return -1
+	case len(k0) > len(k1):
+		return 1
+	}
+	return strings.Compare(string(k0), string(k1))
+}
+
 //String Trivial.
 func (k StringKey) String() string {
 	return string(k)
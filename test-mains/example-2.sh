@@ -1,26 +1,13 @@
-#!/usr/bin/env zsh
-cd $GOPATH/src/github.com/lleo/go-bptree-functional/test-mains/
-go build bptree-functional-test.go
-
-time ./bptree-functional-test -o 3 -r -n 200000 -dont-print-ops > /dev/null
-
-echo
-time ./bptree-functional-test -o 7 -r -n 200000 -dont-print-ops > /dev/null
-
-echo
-time ./bptree-functional-test -o 11 -r -n 200000 -dont-print-ops > /dev/null
-
-echo
-time ./bptree-functional-test -o 13 -r -n 200000 -dont-print-ops > /dev/null
-
-echo
-time ./bptree-functional-test -o 16 -r -n 200000 -dont-print-ops > /dev/null
-
-echo
-time ./bptree-functional-test -o 19 -r -n 200000 -dont-print-ops > /dev/null
-
-echo
-time ./bptree-functional-test -o 32 -r -n 200000 -dont-print-ops > /dev/null
-
-echo
-time ./bptree-functional-test -o 64 -r -n 200000 -dont-print-ops > /dev/null
+#!/usr/bin/env bash
+#Runs the package's Put/Get/Del benchmarks across orders, with allocation
+#reporting. To measure a change, save the output from before and after it
+#and compare the two with benchstat:
+#
+#    ./example-2.sh > old.txt
+#    ...make the change...
+#    ./example-2.sh > new.txt
+#    benchstat old.txt new.txt
+#
+#COUNT sets how many times each benchmark is run (benchstat wants several).
+cd "$(dirname "$0")/.." || exit 1
+go test -run '^$' -bench 'Put|Get|Del' -benchmem -count "${COUNT:-6}" .
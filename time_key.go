@@ -0,0 +1,35 @@
+package bptree
+
+import (
+	"time"
+)
+
+//TimeKey is a BptKey implementation for points in time; construction is
+//simply TimeKey(mytime). TimeKeys are ordered by the instant they stand
+//for, so the same instant in two locations is the same key.
+type TimeKey time.Time
+
+//Compare returns -1, 0 or 1 as the receiver is before, at the same instant
+//as or after the argument. Keys of other types are ordered by type.
+func (k0 TimeKey) Compare(K1 BptKey) int {
+	k1, ok := K1.(TimeKey)
+	if !ok {
+		return compareRanks(k0, K1)
+	}
+	return time.Time(k0).Compare(time.Time(k1))
+}
+
+//Equals is Compare(K1) == 0.
+func (k0 TimeKey) Equals(K1 BptKey) bool {
+	return k0.Compare(K1) == 0
+}
+
+//LessThan is Compare(K1) < 0.
+func (k0 TimeKey) LessThan(K1 BptKey) bool {
+	return k0.Compare(K1) < 0
+}
+
+//String returns the time in RFC 3339 format.
+func (k TimeKey) String() string {
+	return time.Time(k).Format(time.RFC3339Nano)
+}
@@ -0,0 +1,113 @@
+package bptree
+
+//BpTransient is a mutable editor for a BpTree, in the style of Clojure's
+//transients. It is meant for applying a large number of updates when only
+//the final version matters.
+//
+//A persistent Put() or Del() copies every node on the root-to-leaf path it
+//modifies. A BpTransient copies each node at most once per edit session; the
+//copy is tagged as owned by the session and every later update to it is made
+//in place. Persistent() ends the session and returns the result as an
+//ordinary, immutable BpTree.
+//
+//The BpTree a BpTransient was created from, and every other version, is
+//never modified. A BpTransient must not be used after Persistent() has been
+//called, nor be used from more than one goroutine at a time.
+//
+type BpTransient interface {
+	Get(BptKey) (interface{}, bool)
+	Put(BptKey, interface{}) bool
+	Del(BptKey) (interface{}, bool)
+	NumberOfEntries() int
+	Persistent() BpTree
+}
+
+//editT identifies one transient edit session. Nodes whose edit field points
+//at the session's editT were created during the session and may be modified
+//in place by it. It must not be a zero sized type, so that every session
+//gets a distinct pointer.
+type editT struct {
+	live bool
+}
+
+type transientS struct {
+	t *tree
+}
+
+//Transient returns a BpTransient that starts out with the contents of t.
+//
+func (t *tree) Transient() BpTransient {
+	tt := t.copy()
+	tt.edit = &editT{live: true}
+	return &transientS{t: tt}
+}
+
+//editLeaf returns a version of leaf that t may modify in place: leaf itself
+//if it is owned by t's edit session, otherwise a copy of leaf that is. Trees
+//that are not transient (t.edit == nil) always get a copy.
+func (t *Tree[K, V]) editLeaf(leaf *leafNodeS[K, V]) *leafNodeS[K, V] {
+	if t.edit != nil && leaf.edit == t.edit {
+		if ASSERT {
+			assertMutable(leaf, "editLeaf")
+		}
+		return leaf
+	}
+	newLeaf := leaf.copy()
+	newLeaf.edit = t.edit
+	return newLeaf
+}
+
+//editNode is editLeaf for interior nodes.
+func (t *Tree[K, V]) editNode(node *interiorNodeS[K, V]) *interiorNodeS[K, V] {
+	if t.edit != nil && node.edit == t.edit {
+		if ASSERT {
+			assertMutable(node, "editNode")
+		}
+		return node
+	}
+	newNode := node.copy()
+	newNode.edit = t.edit
+	return newNode
+}
+
+func (tr *transientS) tree() *tree {
+	if !tr.t.edit.live {
+		lgr.Panic("BpTransient used after Persistent() was called")
+	}
+	return tr.t
+}
+
+//Get(key) returns the value stored for key, and a boolean that indicates
+//if it was found or not.
+func (tr *transientS) Get(key BptKey) (interface{}, bool) {
+	return tr.tree().Get(key)
+}
+
+//Put(key, val) stores val for key. It returns true if key was added rather
+//than having its value replaced.
+func (tr *transientS) Put(key BptKey, val interface{}) bool {
+	_, added := tr.tree().put(key, val)
+	return added
+}
+
+//Del(key) removes key. It returns the value that was stored for key and a
+//boolean that indicates if key was found.
+func (tr *transientS) Del(key BptKey) (interface{}, bool) {
+	return tr.tree().del(key)
+}
+
+//NumberOfEntries() returns the number of entries in the transient.
+func (tr *transientS) NumberOfEntries() int {
+	return tr.tree().numEnts
+}
+
+//Persistent ends the edit session and returns its result as an immutable
+//BpTree. The nodes the session owned remain tagged with its editT, but since
+//no other session can have the same editT, they will be copied like any
+//other node by later Put()s, Del()s and transients.
+func (tr *transientS) Persistent() BpTree {
+	t := tr.tree()
+	t.edit.live = false
+	nt := t.copy()
+	return nt.freeze()
+}
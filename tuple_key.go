@@ -0,0 +1,127 @@
+package bptree
+
+import (
+	"cmp"
+	"strings"
+)
+
+//TupleKey is a BptKey implementation for composite keys, such as the
+//(tenantID, timestamp, id) keys of a secondary index. Its components are
+//themselves BptKeys, of any mix of types, and tuples are ordered component
+//by component; construction is simply
+//
+//    TupleKey{IntKey(tenant), Desc{TimeKey(ts)}, IntKey(id)}
+//
+//Wrapping a component in Desc reverses its order. A tuple that is a prefix
+//of a longer one sorts just before it, so every key starting with a prefix
+//can be scanned with
+//
+//    prefix := TupleKey{IntKey(7)}
+//    t.Range(prefix, true, prefix.PrefixEnd(), false) //all keys with tenantID=7
+type TupleKey []BptKey
+
+//Compare returns -1, 0 or 1 as the receiver is less than, equal to or
+//greater than the argument. Keys of other types are ordered by type.
+func (k0 TupleKey) Compare(K1 BptKey) int {
+	k1, ok := K1.(TupleKey)
+	if !ok {
+		return compareRanks(k0, K1)
+	}
+	for i := 0; i < len(k0) && i < len(k1); i++ {
+		if c := compareTupleElems(k0[i], k1[i]); c != 0 {
+			return c
+		}
+	}
+	return cmp.Compare(len(k0), len(k1))
+}
+
+//Equals is Compare(K1) == 0.
+func (k0 TupleKey) Equals(K1 BptKey) bool {
+	return k0.Compare(K1) == 0
+}
+
+//LessThan is Compare(K1) < 0.
+func (k0 TupleKey) LessThan(K1 BptKey) bool {
+	return k0.Compare(K1) < 0
+}
+
+//String returns the components, comma separated, in parentheses.
+func (k TupleKey) String() string {
+	elems := make([]string, len(k))
+	for i, e := range k {
+		elems[i] = e.String()
+	}
+	return "(" + strings.Join(elems, ", ") + ")"
+}
+
+//PrefixEnd returns a key that is greater than every TupleKey that starts
+//with the components of k, and less than every other TupleKey greater than
+//k. It is meant as the exclusive upper bound of a range scan over a prefix.
+func (k TupleKey) PrefixEnd() TupleKey {
+	end := make(TupleKey, len(k), len(k)+1)
+	copy(end, k)
+	return append(end, tupleEndT{})
+}
+
+func compareTupleElems(a, b BptKey) int {
+	_, aEnd := a.(tupleEndT)
+	_, bEnd := b.(tupleEndT)
+	switch {
+	case aEnd && bEnd:
+		return 0
+	case aEnd:
+		return 1
+	case bEnd:
+		return -1
+	}
+	return defaultCompare(a, b)
+}
+
+//tupleEndT is the last component of a PrefixEnd() key; it is greater than
+//any other component.
+type tupleEndT struct{}
+
+func (tupleEndT) Equals(K1 BptKey) bool {
+	_, ok := K1.(tupleEndT)
+	return ok
+}
+
+func (tupleEndT) LessThan(K1 BptKey) bool {
+	return false
+}
+
+func (tupleEndT) String() string {
+	return "<end>"
+}
+
+//Desc wraps a TupleKey component to sort it in descending order, for
+//instance to list the newest entries of a tenant first with keys like
+//TupleKey{IntKey(tenant), Desc{TimeKey(ts)}}.
+type Desc struct {
+	Key BptKey
+}
+
+//Compare returns the opposite of what comparing the wrapped keys returns.
+//Keys of other types are ordered by type.
+func (d Desc) Compare(K1 BptKey) int {
+	d1, ok := K1.(Desc)
+	if !ok {
+		return compareRanks(d, K1)
+	}
+	return defaultCompare(d1.Key, d.Key)
+}
+
+//Equals is Compare(K1) == 0.
+func (d Desc) Equals(K1 BptKey) bool {
+	return d.Compare(K1) == 0
+}
+
+//LessThan is Compare(K1) < 0.
+func (d Desc) LessThan(K1 BptKey) bool {
+	return d.Compare(K1) < 0
+}
+
+//String returns the String() of the wrapped key.
+func (d Desc) String() string {
+	return d.Key.String()
+}
@@ -0,0 +1,38 @@
+package bptree
+
+import (
+	"cmp"
+	"strconv"
+)
+
+//Uint64Key is a BptKey implementation for unsigned integers; construction is
+//simply Uint64Key(myuint). Uint64Keys are ordered numerically, and compare
+//numerically with IntKeys too.
+type Uint64Key uint64
+
+//Compare returns -1, 0 or 1 as the receiver is less than, equal to or
+//greater than the argument. Keys of other types are ordered by type.
+func (k0 Uint64Key) Compare(K1 BptKey) int {
+	switch k1 := K1.(type) {
+	case Uint64Key:
+		return cmp.Compare(k0, k1)
+	case IntKey:
+		return -compareIntUint(int64(k1), uint64(k0))
+	}
+	return compareRanks(k0, K1)
+}
+
+//Equals is Compare(K1) == 0.
+func (k0 Uint64Key) Equals(K1 BptKey) bool {
+	return k0.Compare(K1) == 0
+}
+
+//LessThan is Compare(K1) < 0.
+func (k0 Uint64Key) LessThan(K1 BptKey) bool {
+	return k0.Compare(K1) < 0
+}
+
+//String Trivial.
+func (k Uint64Key) String() string {
+	return strconv.FormatUint(uint64(k), 10)
+}
@@ -4,45 +4,58 @@ import (
 	"math"
 )
 
-func validTree(t *tree) bool {
+func validTree[K, V any](t *Tree[K, V]) bool {
 	if !validRootNode(t.root, t.order) {
 		return false
 	}
+	if t.root.count() != t.numEnts {
+		lgr.Printf("t.root.count(),%d != t.numEnts,%d", t.root.count(), t.numEnts)
+		return false
+	}
 	if t.root.isLeaf() {
 		return true //else validRootNode(t.root) would have caught it
 	}
 
-	rootNode := t.root.(*interiorNodeS)
+	rootNode := t.root.(*interiorNodeS[K, V])
 
-	nodes := make([]nodeI, 0, 2)
+	nodes := make([]nodeI[K, V], 0, 2)
+	depths := make([]int, 0, 2) //depths[i] is the depth of nodes[i]
 
 	//seed the nodes slice
 	for i := 0; i < len(rootNode.vals); i++ {
 		nodes = append(nodes, rootNode.vals[i])
+		depths = append(depths, 1)
 	}
 
 	for i := 0; i < len(nodes); i++ {
 		if nodes[i].isLeaf() {
-			node := nodes[i].(*leafNodeS)
+			node := nodes[i].(*leafNodeS[K, V])
+			if depths[i] != t.depth {
+				lgr.Printf("leaf depth,%d != t.depth,%d leaf=\n%v", depths[i], t.depth, node)
+				return false
+			}
 			if !validLeafNode(node, t.order) {
 				lgr.Printf("!validLeafNode(node, t.order) node=\n%v", node)
 				return false
 			}
 		} else {
-			node := nodes[i].(*interiorNodeS)
+			node := nodes[i].(*interiorNodeS[K, V])
 			if !validInteriorNode(node, t.order) {
 				lgr.Printf("!validInteriorNode(node, t.order) node=\n%v", node)
 				return false
 			}
 			nodes = append(nodes, node.vals...)
+			for range node.vals {
+				depths = append(depths, depths[i]+1)
+			}
 		}
 	}
 	return true
 }
 
-func validRootNode(node nodeI, order int) bool {
+func validRootNode[K, V any](node nodeI[K, V], order int) bool {
 	if node.isLeaf() {
-		node := node.(*leafNodeS)
+		node := node.(*leafNodeS[K, V])
 
 		if !(len(node.keys) >= 0 && len(node.keys) <= order-1) {
 			lgr.Printf("!(len(node.keys),%d >= 0 && len(node.keys),%d <= order-1,%d) root=\n%v", len(node.keys), len(node.keys), cap(node.keys)-1, node)
@@ -53,7 +66,7 @@ func validRootNode(node nodeI, order int) bool {
 			return false
 		}
 	} else {
-		node := node.(*interiorNodeS)
+		node := node.(*interiorNodeS[K, V])
 
 		if !(len(node.keys) >= 1 && len(node.keys) <= order-1) {
 			lgr.Printf("validRootNode: !(len(node.keys),%d >= 1 && len(node.keys),%d <= order-1,%d) root=\n%v", len(node.keys), len(node.keys), cap(node.keys)-1, node)
@@ -63,12 +76,16 @@ func validRootNode(node nodeI, order int) bool {
 			lgr.Printf("len(node.keys),%d != len(node.vals)-1,%d root=\n%v", len(node.keys), len(node.vals)-1, node)
 			return false
 		}
+		if !validNodeCnts(node) {
+			lgr.Printf("!validNodeCnts(node) root=\n%v", node)
+			return false
+		}
 	}
 	return true
 }
 
-func validInteriorNode(n_ nodeI, order int) bool {
-	node, ok := n_.(*interiorNodeS)
+func validInteriorNode[K, V any](n_ nodeI[K, V], order int) bool {
+	node, ok := n_.(*interiorNodeS[K, V])
 	if !ok {
 		lgr.Printf("The Node passed in is not castable to *interiorNodeS")
 		return false
@@ -86,11 +103,15 @@ func validInteriorNode(n_ nodeI, order int) bool {
 		lgr.Printf("len(node.keys),%d != len(node.vals)-1,%d node=\n%v", len(node.keys), len(node.vals)-1, node)
 		return false
 	}
+	if !validNodeCnts(node) {
+		lgr.Printf("!validNodeCnts(node) node=\n%v", node)
+		return false
+	}
 	return true
 }
 
-func validLeafNode(node_ nodeI, order int) bool {
-	node, ok := node_.(*leafNodeS)
+func validLeafNode[K, V any](node_ nodeI[K, V], order int) bool {
+	node, ok := node_.(*leafNodeS[K, V])
 	if !ok {
 		lgr.Printf("The Node passed in is not castable to *leafNodeS")
 		return false
@@ -115,7 +136,7 @@ func intCeil(n, d int) int {
 	return int(math.Ceil(float64(n) / float64(d)))
 }
 
-func validLeafKeys(keys []BptKey, order int) bool {
+func validLeafKeys[K any](keys []K, order int) bool {
 	if !(len(keys) >= intCeil(order-1, 2) && len(keys) <= order-1) {
 		lgr.Printf("!(len(keys),%d >= intCeil(order-1, 2),%d && len(keys),%d <= order-1),%d", len(keys), intCeil(order-1, 2), len(keys), order)
 		return false
@@ -123,7 +144,7 @@ func validLeafKeys(keys []BptKey, order int) bool {
 	return true
 }
 
-func validLeafVals(vals []interface{}, order int) bool {
+func validLeafVals[V any](vals []V, order int) bool {
 	if !(len(vals) >= intCeil(order-1, 2) && len(vals) <= order-1) {
 		lgr.Printf("!(len(vals),%d >= intCeil(order-1, 2),%d && len(vals),%d <= order-1),%d", len(vals), intCeil(order-1, 2), len(vals), order)
 		return false
@@ -131,7 +152,7 @@ func validLeafVals(vals []interface{}, order int) bool {
 	return true
 }
 
-func validNodeKeys(keys []BptKey, order int) bool {
+func validNodeKeys[K any](keys []K, order int) bool {
 	if !(len(keys) >= intCeil(order, 2)-1 && len(keys) <= order-1) {
 		lgr.Printf("!(len(keys),%d >= intCeil(order, 2)-1,%d && len(keys),%d <= order-1),%d", len(keys), intCeil(order, 2)-1, len(keys), order-1)
 		return false
@@ -139,10 +160,24 @@ func validNodeKeys(keys []BptKey, order int) bool {
 	return true
 }
 
-func validNodeVals(vals []nodeI, order int) bool {
+func validNodeVals[K, V any](vals []nodeI[K, V], order int) bool {
 	if !(len(vals) >= intCeil(order, 2) && len(vals) <= order) {
 		lgr.Printf("!(len(vals),%d >= intCeil(order, 2),%d && len(vals),%d <= order,%d)", len(vals), intCeil(order, 2), len(vals), order)
 		return false
 	}
 	return true
 }
+
+func validNodeCnts[K, V any](node *interiorNodeS[K, V]) bool {
+	if len(node.cnts) != len(node.vals) {
+		lgr.Printf("len(node.cnts),%d != len(node.vals),%d", len(node.cnts), len(node.vals))
+		return false
+	}
+	for i, v := range node.vals {
+		if node.cnts[i] != v.count() {
+			lgr.Printf("node.cnts[%d],%d != node.vals[%d].count(),%d", i, node.cnts[i], i, v.count())
+			return false
+		}
+	}
+	return true
+}